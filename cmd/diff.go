@@ -0,0 +1,72 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"ports-service/internal/ports/service"
+)
+
+// runDiff compares the PORTS_JSON_PATH file, or the file given as argument, to the ports stored in Redis
+// or to another file, printing the added, removed and modified ports.
+func runDiff(args []string) {
+	flags := flag.NewFlagSet("diff", flag.ExitOnError)
+	against := flags.String("against", "", "compare the ports file to the given ports file rather than to Redis")
+	asJSON := flags.Bool("json", false, "print the changes as newline delimited JSON, and the summary to stderr")
+	_ = flags.Parse(args)
+
+	filePath := flags.Arg(0)
+	if filePath == "" {
+		filePath = os.Getenv("PORTS_JSON_PATH")
+	}
+	if filePath == "" {
+		fmt.Println("PORTS_JSON_PATH environment variable not set")
+		os.Exit(1)
+	}
+
+	// Optionally tune the diff with PORTS_DIFF_PARTITION_SIZE
+	opts := []service.Option{
+		service.WithDiffPartitionSize(intFromEnv("PORTS_DIFF_PARTITION_SIZE")),
+	}
+
+	printChange := func(change service.PortChange) error {
+		_, err := fmt.Print(change.String())
+		return err
+	}
+	summary := os.Stdout
+	if *asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		printChange = func(change service.PortChange) error {
+			return encoder.Encode(change)
+		}
+		summary = os.Stderr
+	}
+
+	ctx, stop := shutdownContext()
+	defer stop()
+	open := func() (service.Source, error) {
+		return openSource(filePath, false)
+	}
+
+	var (
+		report service.DiffReport
+		err    error
+	)
+	if *against != "" {
+		srv := service.NewPortService(nil, opts...)
+		report, err = srv.DiffSources(ctx, func() (service.Source, error) {
+			return openSource(*against, false)
+		}, open, printChange)
+	} else {
+		srv := service.NewPortService(newRedisRepository(), opts...)
+		report, err = srv.DiffRepository(ctx, open, printChange)
+	}
+
+	fmt.Fprint(summary, report.String())
+	if err != nil {
+		fmt.Fprintf(summary, "Failed to compare ports: %v\n", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"ports-service/internal/ports/domain"
+	"ports-service/internal/ports/service"
+)
+
+// runLocalTime prints the current local time at the ports stored in Redis with the UNLOCs given as arguments,
+// or whether they are within business hours with the -business-hours flag.
+func runLocalTime(args []string) {
+	flags := flag.NewFlagSet("localtime", flag.ExitOnError)
+	businessHours := flags.String("business-hours", "", "print whether the ports are within the given business hours, Monday to Friday, such as 09:00-17:00")
+	_ = flags.Parse(args)
+	if flags.NArg() == 0 {
+		fmt.Println("Usage: localtime [-business-hours 09:00-17:00] UNLOC...")
+		os.Exit(1)
+	}
+
+	unlocs := make([]domain.UNLOCODE, flags.NArg())
+	for i, arg := range flags.Args() {
+		unloc, err := domain.ParseUNLOCODE(arg)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		unlocs[i] = unloc
+	}
+
+	ctx, stop := shutdownContext()
+	defer stop()
+	srv := service.NewPortService(newRedisRepository())
+	now := time.Now()
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if *businessHours != "" {
+		hours, err := domain.ParseBusinessHours(*businessHours)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		statuses, err := srv.BusinessHours(ctx, unlocs, hours, now)
+		if err != nil {
+			fmt.Printf("Failed to check business hours: %v\n", err)
+			os.Exit(1)
+		}
+		for _, status := range statuses {
+			if err := encoder.Encode(status); err != nil {
+				fmt.Printf("Failed to print %s: %v\n", status.UNLOC, err)
+				os.Exit(1)
+			}
+		}
+		return
+	}
+
+	missing := false
+	for _, unloc := range unlocs {
+		portTime, err := srv.LocalTime(ctx, unloc, now)
+		if err != nil {
+			fmt.Printf("Failed to get the local time at %s: %v\n", unloc, err)
+			os.Exit(1)
+		}
+		if portTime == nil {
+			fmt.Fprintf(os.Stderr, "Port %s not found\n", unloc)
+			missing = true
+			continue
+		}
+		if err := encoder.Encode(portTime); err != nil {
+			fmt.Printf("Failed to print %s: %v\n", unloc, err)
+			os.Exit(1)
+		}
+	}
+	if missing {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"ports-service/internal/ports/domain"
+	"ports-service/internal/ports/service"
+)
+
+// runLookup prints the ports stored in Redis with the UNLOCs given as arguments, along with their provenance.
+func runLookup(args []string) {
+	flags := flag.NewFlagSet("lookup", flag.ExitOnError)
+	_ = flags.Parse(args)
+	if flags.NArg() == 0 {
+		fmt.Println("Usage: lookup UNLOC...")
+		os.Exit(1)
+	}
+
+	ctx, stop := shutdownContext()
+	defer stop()
+	srv := service.NewPortService(newRedisRepository())
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	missing := false
+	for _, arg := range flags.Args() {
+		unloc, err := domain.ParseUNLOCODE(arg)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		lookup, err := srv.GetPort(ctx, unloc)
+		if err != nil {
+			fmt.Printf("Failed to look %s up: %v\n", unloc, err)
+			os.Exit(1)
+		}
+		if lookup == nil {
+			fmt.Fprintf(os.Stderr, "Port %s not found\n", unloc)
+			missing = true
+			continue
+		}
+		if err := encoder.Encode(lookup); err != nil {
+			fmt.Printf("Failed to print %s: %v\n", unloc, err)
+			os.Exit(1)
+		}
+	}
+	if missing {
+		os.Exit(1)
+	}
+}
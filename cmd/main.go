@@ -2,19 +2,176 @@ package main
 
 import (
 	"context"
+	"flag"
 	"fmt"
+	"io"
 	"os"
 	"os/signal"
+	"ports-service/internal/infra/repository/inmemory"
 	"ports-service/internal/infra/repository/redis"
+	"strconv"
+	"strings"
 	"syscall"
+	"time"
 
+	"ports-service/internal/infra/deadletter"
+	"ports-service/internal/infra/source"
+	"ports-service/internal/ports/domain"
 	"ports-service/internal/ports/service"
 )
 
+// defaultMemoryBudgetMB is the memory, in megabytes, the service runs in.
+const defaultMemoryBudgetMB = 200
+
 func main() {
-	terminateCh := make(chan os.Signal, 1)
-	signal.Notify(terminateCh, syscall.SIGINT, syscall.SIGTERM)
+	if len(os.Args) > 1 && os.Args[1] == "diff" {
+		runDiff(os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "lookup" {
+		runLookup(os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "localtime" {
+		runLocalTime(os.Args[2:])
+		return
+	}
+
+	dryRun := flag.Bool("dry-run", false, "validate the ports file without writing it to Redis, exiting with an error when it holds invalid ports")
+	deadLetterPath := flag.String("dead-letter", "", "write the rejected ports to the given NDJSON file, which can be imported again once fixed")
+	fullSync := flag.Bool("sync", false, "delete the stored ports missing from the ports file once it is successfully imported")
+	watch := flag.Bool("watch", false, "keep running and import the ports file, or the files of the PORTS_JSON_PATH directory, whenever they change")
+	flag.Parse()
+
+	// Optionally tune the import with PORTS_IMPORT_WORKERS and PORTS_IMPORT_BATCH_SIZE
+	opts := []service.Option{
+		progressOption(),
+		service.WithWorkers(intFromEnv("PORTS_IMPORT_WORKERS")),
+		service.WithBatchSize(intFromEnv("PORTS_IMPORT_BATCH_SIZE")),
+		// Optionally give the ports in flight more or less time to be written on shutdown with PORTS_IMPORT_DRAIN_TIMEOUT_SECONDS
+		service.WithDrainTimeout(time.Duration(intFromEnv("PORTS_IMPORT_DRAIN_TIMEOUT_SECONDS")) * time.Second),
+		// Rejected ports never abort the import unless PORTS_IMPORT_MAX_FAILURES or PORTS_IMPORT_MAX_FAILURE_PERCENT is set
+		service.WithErrorPolicy(service.ErrorPolicy{
+			MaxFailures:    intFromEnv("PORTS_IMPORT_MAX_FAILURES"),
+			MaxFailureRate: floatFromEnv("PORTS_IMPORT_MAX_FAILURE_PERCENT") / 100,
+		}),
+	}
+
+	// The import stays within the 200MB the service runs in unless PORTS_MEMORY_BUDGET_MB says otherwise
+	memoryBudget := intFromEnv("PORTS_MEMORY_BUDGET_MB")
+	if memoryBudget == 0 {
+		memoryBudget = defaultMemoryBudgetMB
+	}
+	opts = append(opts, service.WithMemoryBudget(int64(memoryBudget)<<20))
+
+	if policy := os.Getenv("PORTS_DUPLICATE_POLICY"); policy != "" {
+		opts = append(opts, service.WithDuplicatePolicy(service.DuplicatePolicy(policy)))
+	}
+	if policy := os.Getenv("PORTS_MISSING_FILE_POLICY"); policy != "" {
+		opts = append(opts, service.WithMissingSourcePolicy(service.MissingSourcePolicy(policy)))
+	}
+	// Stored ports are replaced as a whole unless PORTS_MERGE_POLICY merges them field by field
+	if value := os.Getenv("PORTS_MERGE_POLICY"); value != "" {
+		policy, err := domain.ParseMergePolicy(value)
+		if err != nil {
+			fmt.Printf("Invalid PORTS_MERGE_POLICY: %v\n", err)
+			os.Exit(1)
+		}
+		opts = append(opts, service.WithMergePolicy(policy))
+	}
+
+	var repo service.PortRepository
+	if *dryRun {
+		// A dry run never writes to the repository, so it does not need Redis
+		repo = inmemory.NewPortRepository()
+		opts = append(opts, service.WithDryRun(), service.WithFailureHandler(printInvalidPort))
+	} else {
+		repo = newRedisRepository()
+	}
+	if *fullSync {
+		opts = append(opts, service.WithFullSync())
+	}
+	if *deadLetterPath != "" {
+		deadLetterFile, err := os.Create(*deadLetterPath)
+		if err != nil {
+			fmt.Printf("Failed to create dead-letter file: %v\n", err)
+			os.Exit(1)
+		}
+		defer deadLetterFile.Close()
+		opts = append(opts, service.WithFailureHandler(deadletter.NewNDJSONWriter(deadLetterFile).Reject))
+	}
+	srv := service.NewPortService(repo, opts...)
+
+	// Load ports from the files given as arguments, or else from the comma separated PORTS_JSON_PATH files
+	paths := flag.Args()
+	if len(paths) == 0 && os.Getenv("PORTS_JSON_PATH") != "" {
+		paths = strings.Split(os.Getenv("PORTS_JSON_PATH"), ",")
+	}
+	if len(paths) == 0 {
+		fmt.Println("PORTS_JSON_PATH environment variable not set")
+		os.Exit(1)
+	}
+
+	ctx, stop := shutdownContext()
+	defer stop()
+
+	if *watch {
+		if len(paths) > 1 || paths[0] == source.Stdin {
+			fmt.Println("Watch mode watches a single ports file or directory")
+			os.Exit(1)
+		}
+		// Each file of a directory is imported on its own, a full sync would delete the ports of the others
+		if info, err := os.Stat(paths[0]); *fullSync && (err != nil || info.IsDir()) {
+			fmt.Println("Watch mode only syncs an existing ports file, not the files of a directory")
+			os.Exit(1)
+		}
+		runWatch(ctx, srv, paths[0])
+		return
+	}
 
+	files, err := source.ExpandPaths(paths)
+	if err != nil {
+		fmt.Printf("Invalid ports file pattern: %v\n", err)
+		os.Exit(1)
+	}
+	report, err := srv.LoadSources(ctx, files, func(path string) (service.Source, error) {
+		return openSource(path, true)
+	})
+	fmt.Print(report.String())
+	if err != nil {
+		fmt.Printf("Failed to load ports from file: %v\n", err)
+		os.Exit(1)
+	}
+	if *dryRun && report.Invalid > 0 {
+		fmt.Printf("Found %d invalid ports\n", report.Invalid)
+		os.Exit(1)
+	}
+}
+
+// importFile imports the ports file at the given path.
+func importFile(ctx context.Context, srv *service.PortService, path string) (service.ImportReport, error) {
+	src, err := openSource(path, true)
+	if err != nil {
+		return service.ImportReport{}, err
+	}
+	defer src.Reader.(io.Closer).Close()
+
+	return srv.LoadSource(ctx, src)
+}
+
+// shutdownContext returns a context canceled by SIGINT or SIGTERM, which stops reading the ports file
+// while the ports in flight are written. A second signal terminates the application right away.
+func shutdownContext() (context.Context, context.CancelFunc) {
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+	return ctx, stop
+}
+
+// newRedisRepository creates the Redis repository located by the REDIS_URL environment variable.
+func newRedisRepository() *redis.PortRepository {
 	redisURL := os.Getenv("REDIS_URL")
 	if redisURL == "" {
 		fmt.Println("REDIS_URL environment variable not set")
@@ -26,33 +183,46 @@ func main() {
 		fmt.Printf("Failed to create Redis repository: %v\n", err)
 		os.Exit(1)
 	}
-	srv := service.NewPortService(repo)
+	return repo
+}
 
-	// Load ports from the PORTS_JSON_PATH file
-	filePath := os.Getenv("PORTS_JSON_PATH")
-	if filePath == "" {
-		fmt.Println("PORTS_JSON_PATH environment variable not set")
-		os.Exit(1)
+// printInvalidPort prints a port failing validation along with its field errors.
+func printInvalidPort(failure service.ImportFailure) error {
+	fmt.Printf("Invalid port %s at offset %d of %s:\n", failure.UNLOC, failure.Offset, failure.Source)
+	for _, field := range failure.Fields {
+		fmt.Printf("  %s\n", field.Message)
 	}
+	return nil
+}
 
-	file, err := os.Open(filePath)
-	if err != nil {
-		fmt.Printf("Failed to open file: %v\n", err)
-		os.Exit(1)
+// intFromEnv returns the integer value of the given environment variable, or zero when it is not set.
+// It exits the application when the value is not a valid integer.
+func intFromEnv(name string) int {
+	value := os.Getenv(name)
+	if value == "" {
+		return 0
 	}
-	defer file.Close()
 
-	ctx := context.Background()
-
-	err = srv.LoadPorts(ctx, file, terminateCh)
+	n, err := strconv.Atoi(value)
 	if err != nil {
-		fmt.Printf("Failed to load ports from file: %v\n", err)
+		fmt.Printf("Invalid %s value: %v\n", name, err)
 		os.Exit(1)
 	}
+	return n
+}
+
+// floatFromEnv returns the float value of the given environment variable, or zero when it is not set.
+// It exits the application when the value is not a valid number.
+func floatFromEnv(name string) float64 {
+	value := os.Getenv(name)
+	if value == "" {
+		return 0
+	}
 
-	length, err := repo.GetPortsLength(ctx)
+	f, err := strconv.ParseFloat(value, 64)
 	if err != nil {
-		fmt.Printf("Failed to get ports length: %v\n", err)
+		fmt.Printf("Invalid %s value: %v\n", name, err)
+		os.Exit(1)
 	}
-	fmt.Printf("Ports imported: %d\n", length)
+	return f
 }
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"ports-service/internal/ports/service"
+)
+
+const (
+	// terminalProgressInterval is the time between two progress updates on a terminal, where they are rewritten in place.
+	terminalProgressInterval = time.Second
+	// logProgressInterval is the time between two progress lines elsewhere, e.g. in container logs.
+	logProgressInterval = 10 * time.Second
+)
+
+// progressOption returns the option printing the progress of imports to stderr.
+func progressOption() service.Option {
+	info, err := os.Stderr.Stat()
+	if err != nil || info.Mode()&os.ModeCharDevice == 0 {
+		return service.WithProgressHandler(func(progress service.Progress) {
+			// The report printed once the import completes follows the last progress
+			if !progress.Done {
+				fmt.Fprintf(os.Stderr, "Progress: %s\n", progress)
+			}
+		}, logProgressInterval)
+	}
+
+	return service.WithProgressHandler(func(progress service.Progress) {
+		// The line is cleared before being rewritten, and once the import completes
+		fmt.Fprint(os.Stderr, "\r\033[K")
+		if !progress.Done {
+			fmt.Fprint(os.Stderr, progress)
+		}
+	}, terminalProgressInterval)
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync/atomic"
+
+	"ports-service/internal/infra/source"
+	"ports-service/internal/ports/format"
+	"ports-service/internal/ports/service"
+)
+
+// openSource opens the ports file at the given path as a source, in the PORTS_FORMAT format.
+// Compressed files are decompressed on the fly, and closing the reader of the source closes the file.
+// The source is fingerprinted when asked to, which reads the whole file beforehand, unless it is stdin.
+func openSource(path string, fingerprint bool) (service.Source, error) {
+	file := os.Stdin
+	if path == source.Stdin {
+		// Stdin cannot be read twice to be fingerprinted, so its imports are not checkpointed
+		fingerprint = false
+	} else {
+		var err error
+		if file, err = os.Open(path); err != nil {
+			return service.Source{}, fmt.Errorf("failed to open file: %w", err)
+		}
+	}
+
+	src := service.Source{
+		Name:   path,
+		Format: format.Format(os.Getenv("PORTS_FORMAT")),
+	}
+
+	// The fingerprint lets an interrupted import of the same file resume where it stopped
+	if fingerprint {
+		var err error
+		if src.Fingerprint, err = source.Fingerprint(file); err != nil {
+			file.Close()
+			return service.Source{}, fmt.Errorf("failed to read file: %w", err)
+		}
+	}
+
+	// The progress of the import is reported against the size of the file, compressed or not
+	if info, err := file.Stat(); err == nil && info.Mode().IsRegular() {
+		src.Size = info.Size()
+	}
+	reader := &sourceReader{file: file}
+	content, err := source.Decompress(fileReader{reader})
+	if err != nil {
+		file.Close()
+		return service.Source{}, fmt.Errorf("failed to decompress file: %w", err)
+	}
+	reader.ReadCloser = content
+	src.Reader = reader
+	return src, nil
+}
+
+// sourceReader reads the decompressed content of a file, closing both when closed.
+// It tells how many bytes of the file were read, as a service.SourceProgress.
+type sourceReader struct {
+	io.ReadCloser
+	file *os.File
+	read atomic.Int64
+}
+
+// fileReader reads the file of a sourceReader, counting the bytes read.
+type fileReader struct {
+	r *sourceReader
+}
+
+func (f fileReader) Read(p []byte) (int, error) {
+	n, err := f.r.file.Read(p)
+	f.r.read.Add(int64(n))
+	return n, err
+}
+
+func (r *sourceReader) SourceBytesRead() int64 {
+	return r.read.Load()
+}
+
+func (r *sourceReader) Close() error {
+	err := r.ReadCloser.Close()
+	if fileErr := r.file.Close(); err == nil {
+		err = fileErr
+	}
+	return err
+}
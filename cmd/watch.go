@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"ports-service/internal/infra/source"
+	"ports-service/internal/ports/service"
+)
+
+const (
+	// defaultWatchInterval is the time between two polls of the watched ports files.
+	defaultWatchInterval = 10 * time.Second
+	// defaultWatchSettle is the time a ports file must stay the same before being imported.
+	defaultWatchSettle = 5 * time.Second
+	// defaultStatusAddr is the address the status of the watch mode is served on.
+	defaultStatusAddr = ":8080"
+)
+
+// runWatch imports the ports file, or the ports files of the directory, at the given path whenever they change,
+// until the context is canceled. Imports run one at a time, and the status of the last one is served as JSON.
+// Failed imports are retried at every poll until they succeed.
+func runWatch(ctx context.Context, srv *service.PortService, path string) {
+	interval := time.Duration(intFromEnv("PORTS_WATCH_INTERVAL_SECONDS")) * time.Second
+	if interval == 0 {
+		interval = defaultWatchInterval
+	}
+	settle := time.Duration(intFromEnv("PORTS_WATCH_SETTLE_SECONDS")) * time.Second
+	if settle == 0 {
+		settle = defaultWatchSettle
+	}
+	addr := os.Getenv("PORTS_STATUS_ADDR")
+	if addr == "" {
+		addr = defaultStatusAddr
+	}
+
+	status := &watchStatus{}
+	server := &http.Server{Addr: addr, Handler: status, ReadHeaderTimeout: 5 * time.Second}
+	go func() {
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			fmt.Printf("Failed to serve the watch status: %v\n", err)
+		}
+	}()
+	defer server.Close()
+
+	fmt.Printf("Watching %s for changes, status served on %s\n", path, addr)
+	watcher := source.NewWatcher(path, interval, settle)
+	err := watcher.Watch(ctx, func(path, checksum string) error {
+		status.start(path, checksum)
+		fmt.Printf("Importing %s\n", path)
+		report, err := importFile(ctx, srv, path)
+		fmt.Print(report.String())
+		if err != nil {
+			fmt.Printf("Failed to load ports from file: %v\n", err)
+		}
+		status.finish(report, err)
+		// Failed imports are retried by the next poll
+		return err
+	})
+	if err != nil && !errors.Is(err, context.Canceled) {
+		fmt.Printf("Failed to watch %s: %v\n", path, err)
+		os.Exit(1)
+	}
+}
+
+// watchStatus is the status of the imports run by the watch mode, served as JSON.
+type watchStatus struct {
+	mu sync.Mutex
+	// Running is the import in progress, if any.
+	Running *watchRun `json:"running,omitempty"`
+	// LastRun is the last import completed, successfully or not.
+	LastRun *watchRun `json:"last_run,omitempty"`
+	// Runs is the number of imports completed.
+	Runs int `json:"runs"`
+}
+
+// watchRun is an import run by the watch mode.
+type watchRun struct {
+	Path      string    `json:"path"`
+	Checksum  string    `json:"checksum"`
+	StartedAt time.Time `json:"started_at"`
+	// The fields below are set once the import completed.
+	Elapsed          string `json:"elapsed,omitempty"`
+	Inserted         int    `json:"inserted"`
+	Updated          int    `json:"updated"`
+	Unchanged        int    `json:"unchanged"`
+	Valid            int    `json:"valid"`
+	Invalid          int    `json:"invalid"`
+	Failed           int    `json:"failed"`
+	Deleted          int    `json:"deleted"`
+	Skipped          int    `json:"skipped"`
+	ProvenanceFailed int    `json:"provenance_failed"`
+	Error            string `json:"error,omitempty"`
+}
+
+func (s *watchStatus) start(path, checksum string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Running = &watchRun{Path: path, Checksum: checksum, StartedAt: time.Now()}
+}
+
+func (s *watchStatus) finish(report service.ImportReport, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	run := s.Running
+	run.Elapsed = report.Elapsed.Round(time.Millisecond).String()
+	run.Inserted = report.Inserted
+	run.Updated = report.Updated
+	run.Unchanged = report.Unchanged
+	run.Valid = report.Valid
+	run.Invalid = report.Invalid
+	run.Failed = report.Failed
+	run.Deleted = report.Deleted
+	run.Skipped = report.Skipped
+	run.ProvenanceFailed = report.ProvenanceFailed
+	if err != nil {
+		run.Error = err.Error()
+	}
+	s.Running = nil
+	s.LastRun = run
+	s.Runs++
+}
+
+func (s *watchStatus) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(s)
+}
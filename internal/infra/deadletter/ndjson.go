@@ -0,0 +1,72 @@
+// Package deadletter stores the ports rejected by imports, so that they can be fixed and imported again.
+package deadletter
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"ports-service/internal/ports/service"
+)
+
+// rejection describes why a port was rejected.
+type rejection struct {
+	Stage  service.ImportStage `json:"stage"`
+	Source string              `json:"source,omitempty"`
+	Offset int64               `json:"offset"`
+	Error  string              `json:"error"`
+}
+
+// NDJSONWriter writes the rejected ports as newline delimited JSON.
+//
+// Each line holds the port as found in the source, along with its "unloc" and a "rejection"
+// field describing the stage which rejected it, its source and offset in it, and the error.
+// Once fixed, the file can be imported again as is, since the rejection field is ignored.
+type NDJSONWriter struct {
+	w   io.Writer
+	buf bytes.Buffer
+}
+
+// NewNDJSONWriter creates a new instance of NDJSONWriter writing to w.
+func NewNDJSONWriter(w io.Writer) *NDJSONWriter {
+	return &NDJSONWriter{w: w}
+}
+
+// Reject writes the rejected port as a single line.
+// It is a service.FailureHandler, and as such is not safe for concurrent use.
+func (w *NDJSONWriter) Reject(failure service.ImportFailure) error {
+	var record bytes.Buffer
+	if err := json.Compact(&record, failure.Record); err != nil {
+		return err
+	}
+	if !bytes.HasPrefix(record.Bytes(), []byte("{")) {
+		return fmt.Errorf("port %s is not a JSON object", failure.UNLOC)
+	}
+
+	unloc, err := json.Marshal(failure.UNLOC)
+	if err != nil {
+		return err
+	}
+	rej, err := json.Marshal(rejection{Stage: failure.Stage, Source: failure.Source, Offset: failure.Offset, Error: failure.Reason})
+	if err != nil {
+		return err
+	}
+
+	// The UNLOC goes first, so that the line is told apart from ports keyed by UNLOC
+	w.buf.Reset()
+	w.buf.WriteString(`{"unloc":`)
+	w.buf.Write(unloc)
+	w.buf.WriteString(`,"rejection":`)
+	w.buf.Write(rej)
+	if fields := record.Bytes()[1:]; !bytes.Equal(fields, []byte("}")) {
+		w.buf.WriteByte(',')
+		w.buf.Write(fields)
+	} else {
+		w.buf.WriteByte('}')
+	}
+	w.buf.WriteByte('\n')
+
+	_, err = w.w.Write(w.buf.Bytes())
+	return err
+}
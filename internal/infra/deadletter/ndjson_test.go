@@ -0,0 +1,70 @@
+package deadletter_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"ports-service/internal/infra/deadletter"
+	"ports-service/internal/infra/repository/inmemory"
+	"ports-service/internal/ports/service"
+)
+
+func TestNDJSONWriter_Reject(t *testing.T) {
+	var out bytes.Buffer
+	writer := deadletter.NewNDJSONWriter(&out)
+
+	err := writer.Reject(service.ImportFailure{
+		UNLOC:  "AEJEA",
+		Stage:  service.StageValidate,
+		Offset: 42,
+		Reason: "validation error",
+		Record: json.RawMessage("{\n  \"name\": \"Jebel Ali\"\n}"),
+	})
+	assert.NoError(t, err)
+
+	err = writer.Reject(service.ImportFailure{
+		UNLOC:  "AEJED",
+		Stage:  service.StageWrite,
+		Source: "ports.json",
+		Offset: 84,
+		Reason: "write failed",
+		Record: json.RawMessage("{}"),
+	})
+	assert.NoError(t, err)
+
+	assert.Equal(t, `{"unloc":"AEJEA","rejection":{"stage":"validate","offset":42,"error":"validation error"},"name":"Jebel Ali"}`+"\n"+
+		`{"unloc":"AEJED","rejection":{"stage":"write","source":"ports.json","offset":84,"error":"write failed"}}`+"\n", out.String())
+}
+
+func TestNDJSONWriter_Reimport(t *testing.T) {
+	ctx := context.Background()
+
+	// Import a file with an invalid port, writing it to the dead-letter file
+	var out bytes.Buffer
+	writer := deadletter.NewNDJSONWriter(&out)
+	repo := inmemory.NewPortRepository()
+	portService := service.NewPortService(repo, service.WithFailureHandler(writer.Reject))
+
+	report, err := portService.LoadPorts(ctx, strings.NewReader(`{
+		"AEJEA": {"name": "Jebel Ali", "city": "Jebel Ali", "country": "United Arab Emirates"},
+		"AEJED": {"name": "Jebel Dhanna", "city": "Jebel Dhanna"}
+	}`))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, report.Invalid)
+
+	// Once fixed, the dead-letter file goes through the normal import
+	fixed := strings.Replace(out.String(), `"city":"Jebel Dhanna"`, `"city":"Jebel Dhanna","country":"United Arab Emirates"`, 1)
+	report, err = portService.LoadPorts(ctx, strings.NewReader(fixed))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, report.Inserted)
+
+	port, err := repo.GetPortByUNLOC(ctx, "AEJED")
+	assert.NoError(t, err)
+	assert.NotNil(t, port)
+	assert.Equal(t, "Jebel Dhanna", port.Name)
+}
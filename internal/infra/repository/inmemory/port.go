@@ -9,28 +9,113 @@ import (
 
 // PortRepository is an in-memory repository handling ports.
 type PortRepository struct {
-	ports map[string]domain.Port
-	mutex sync.RWMutex
+	ports       map[domain.UNLOCODE]domain.Port
+	checkpoints map[string]domain.Checkpoint
+	provenance  map[domain.UNLOCODE]domain.Provenance
+	mutex       sync.RWMutex
 }
 
 // NewPortRepository creates a new instance of InMemoryPortRepository.
 func NewPortRepository() *PortRepository {
 	return &PortRepository{
-		ports: make(map[string]domain.Port),
+		ports:       make(map[domain.UNLOCODE]domain.Port),
+		checkpoints: make(map[string]domain.Checkpoint),
+		provenance:  make(map[domain.UNLOCODE]domain.Provenance),
 	}
 }
 
 // UpsertPort inserts or updates a port in the repository.
-func (r *PortRepository) UpsertPort(_ context.Context, port domain.Port) error {
+// It reports whether the port was inserted, updated or left unchanged.
+func (r *PortRepository) UpsertPort(_ context.Context, port domain.Port) (domain.UpsertStatus, error) {
 	r.mutex.Lock()
 	defer r.mutex.Unlock()
 
-	r.ports[port.UNLOC] = port
+	return r.upsert(port), nil
+}
+
+// UpsertPorts inserts or updates several ports in the repository.
+// It returns one result per port, none of them failed as in-memory writes cannot fail.
+func (r *PortRepository) UpsertPorts(_ context.Context, ports []domain.Port) []domain.UpsertResult {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	results := make([]domain.UpsertResult, len(ports))
+	for i, port := range ports {
+		results[i].Status = r.upsert(port)
+	}
+	return results
+}
+
+// UpsertPortIf inserts or updates a port in the repository, provided that the stored port is still
+// the previous one, nil meaning that it is not stored. It returns domain.ErrPortChanged otherwise.
+func (r *PortRepository) UpsertPortIf(_ context.Context, port domain.Port, previous *domain.Port) (domain.UpsertStatus, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	current, exists := r.ports[port.UNLOC]
+	if exists != (previous != nil) || exists && current.Hash() != previous.Hash() {
+		return 0, domain.ErrPortChanged
+	}
+	return r.upsert(port), nil
+}
+
+// upsert stores the port, the caller must hold the write lock.
+func (r *PortRepository) upsert(port domain.Port) domain.UpsertStatus {
+	current, exists := r.ports[port.UNLOC]
+	switch {
+	case !exists:
+		r.ports[port.UNLOC] = port
+		return domain.Inserted
+	case current.Hash() == port.Hash():
+		return domain.Unchanged
+	default:
+		r.ports[port.UNLOC] = port
+		return domain.Updated
+	}
+}
+
+// DeletePorts removes the ports with the given UNLOCs, ignoring the ones not stored.
+func (r *PortRepository) DeletePorts(_ context.Context, unlocs []domain.UNLOCODE) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for _, unloc := range unlocs {
+		delete(r.ports, unloc)
+		delete(r.provenance, unloc)
+	}
 	return nil
 }
 
+// ScanUNLOCs calls fn with the UNLOCs of the stored ports, all at once as they are in memory.
+func (r *PortRepository) ScanUNLOCs(ctx context.Context, fn func(unlocs []domain.UNLOCODE) error) error {
+	r.mutex.RLock()
+	unlocs := make([]domain.UNLOCODE, 0, len(r.ports))
+	for unloc := range r.ports {
+		unlocs = append(unlocs, unloc)
+	}
+	r.mutex.RUnlock()
+
+	// The lock is released, so that fn can delete ports
+	return fn(unlocs)
+}
+
+// GetPortHashes returns the hashes of the ports stored with the given UNLOCs, in the same order,
+// and an empty hash for the ports not stored.
+func (r *PortRepository) GetPortHashes(_ context.Context, unlocs []domain.UNLOCODE) ([]string, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	hashes := make([]string, len(unlocs))
+	for i, unloc := range unlocs {
+		if port, exists := r.ports[unloc]; exists {
+			hashes[i] = port.Hash()
+		}
+	}
+	return hashes, nil
+}
+
 // GetPortByUNLOC retrieves a port from the repository by its UNLOC code.
-func (r *PortRepository) GetPortByUNLOC(_ context.Context, unloc string) (*domain.Port, error) {
+func (r *PortRepository) GetPortByUNLOC(_ context.Context, unloc domain.UNLOCODE) (*domain.Port, error) {
 	r.mutex.RLock()
 	defer r.mutex.RUnlock()
 
@@ -41,6 +126,31 @@ func (r *PortRepository) GetPortByUNLOC(_ context.Context, unloc string) (*domai
 	return &port, nil
 }
 
+// GetProvenance retrieves the provenance of the port stored with the given UNLOC.
+func (r *PortRepository) GetProvenance(_ context.Context, unloc domain.UNLOCODE) (*domain.Provenance, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	provenance, exists := r.provenance[unloc]
+	if !exists {
+		return nil, nil
+	}
+	return &provenance, nil
+}
+
+// RecordProvenance updates the provenance of the given ports, written by the origins of the same index.
+func (r *PortRepository) RecordProvenance(_ context.Context, ports []domain.Port, origins []domain.Origin) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for i, port := range ports {
+		if provenance, changed := r.provenance[port.UNLOC].Update(port, origins[i]); changed {
+			r.provenance[port.UNLOC] = provenance
+		}
+	}
+	return nil
+}
+
 // GetPortsLength returns the total number of ports in the repository.
 func (r *PortRepository) GetPortsLength(ctx context.Context) int {
 	r.mutex.RLock()
@@ -48,3 +158,33 @@ func (r *PortRepository) GetPortsLength(ctx context.Context) int {
 
 	return len(r.ports)
 }
+
+// GetCheckpoint retrieves the import checkpoint of the given source.
+func (r *PortRepository) GetCheckpoint(_ context.Context, source string) (*domain.Checkpoint, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	checkpoint, exists := r.checkpoints[source]
+	if !exists {
+		return nil, nil
+	}
+	return &checkpoint, nil
+}
+
+// SaveCheckpoint inserts or updates the import checkpoint of a source.
+func (r *PortRepository) SaveCheckpoint(_ context.Context, checkpoint domain.Checkpoint) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.checkpoints[checkpoint.Source] = checkpoint
+	return nil
+}
+
+// DeleteCheckpoint removes the import checkpoint of the given source.
+func (r *PortRepository) DeleteCheckpoint(_ context.Context, source string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	delete(r.checkpoints, source)
+	return nil
+}
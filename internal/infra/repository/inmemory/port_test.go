@@ -3,6 +3,7 @@ package inmemory_test
 import (
 	"context"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 
@@ -21,8 +22,9 @@ func TestInMemoryPortRepository_UpsertPort(t *testing.T) {
 		UNLOC:   "TEST",
 	}
 
-	err := repo.UpsertPort(ctx, port)
+	status, err := repo.UpsertPort(ctx, port)
 	assert.NoError(t, err, "Expected no error")
+	assert.Equal(t, domain.Inserted, status, "Expected port to be inserted")
 
 	result, err := repo.GetPortByUNLOC(ctx, "TEST")
 	assert.NoError(t, err, "Expected no error")
@@ -47,7 +49,7 @@ func TestInMemoryPortRepository_GetPortByUNLOC(t *testing.T) {
 		Country: "Test Country",
 		UNLOC:   "TEST",
 	}
-	err = repo.UpsertPort(ctx, port)
+	_, err = repo.UpsertPort(ctx, port)
 	assert.NoError(t, err, "Expected no error")
 
 	result, err = repo.GetPortByUNLOC(ctx, "TEST")
@@ -57,3 +59,147 @@ func TestInMemoryPortRepository_GetPortByUNLOC(t *testing.T) {
 	assert.Equal(t, port.City, result.City, "Expected port city to match")
 	assert.Equal(t, port.Country, result.Country, "Expected port country to match")
 }
+
+func TestInMemoryPortRepository_UpsertPorts(t *testing.T) {
+	ctx := context.Background()
+	repo := inmemory.NewPortRepository()
+
+	ports := []domain.Port{
+		{Name: "Port 1", City: "City 1", Country: "Country 1", UNLOC: "PORT1"},
+		{Name: "Port 2", City: "City 2", Country: "Country 2", UNLOC: "PORT2"},
+	}
+
+	results := repo.UpsertPorts(ctx, ports)
+	assert.Equal(t, []domain.UpsertResult{{Status: domain.Inserted}, {Status: domain.Inserted}}, results, "Expected ports to be inserted")
+
+	ports[1].Name = "Port 2 renamed"
+	results = repo.UpsertPorts(ctx, ports)
+	assert.Equal(t, []domain.UpsertResult{{Status: domain.Unchanged}, {Status: domain.Updated}}, results, "Expected only the renamed port to be updated")
+
+	for _, port := range ports {
+		result, err := repo.GetPortByUNLOC(ctx, port.UNLOC)
+		assert.NoError(t, err, "Expected no error")
+		assert.NotNil(t, result, "Expected a non-nil port")
+		assert.Equal(t, port.Name, result.Name, "Expected port name to match")
+	}
+}
+
+func TestInMemoryPortRepository_UpsertPortIf(t *testing.T) {
+	ctx := context.Background()
+	repo := inmemory.NewPortRepository()
+
+	port := domain.Port{Name: "Port 1", City: "City 1", Country: "Country 1", UNLOC: "PORT1"}
+	status, err := repo.UpsertPortIf(ctx, port, nil)
+	assert.NoError(t, err, "Expected no error")
+	assert.Equal(t, domain.Inserted, status, "Expected port to be inserted")
+
+	// The port is stored by now, so it is not written as if it were not
+	_, err = repo.UpsertPortIf(ctx, port, nil)
+	assert.ErrorIs(t, err, domain.ErrPortChanged, "Expected the port to have changed")
+
+	renamed := port
+	renamed.Name = "Port 1 renamed"
+	status, err = repo.UpsertPortIf(ctx, renamed, &port)
+	assert.NoError(t, err, "Expected no error")
+	assert.Equal(t, domain.Updated, status, "Expected port to be updated")
+
+	// The previous port is stale once renamed
+	_, err = repo.UpsertPortIf(ctx, port, &port)
+	assert.ErrorIs(t, err, domain.ErrPortChanged, "Expected the port to have changed")
+
+	status, err = repo.UpsertPortIf(ctx, renamed, &renamed)
+	assert.NoError(t, err, "Expected no error")
+	assert.Equal(t, domain.Unchanged, status, "Expected port to be unchanged")
+
+	result, err := repo.GetPortByUNLOC(ctx, port.UNLOC)
+	assert.NoError(t, err, "Expected no error")
+	assert.Equal(t, renamed.Name, result.Name, "Expected port name to match")
+}
+
+func TestInMemoryPortRepository_GetPortHashes(t *testing.T) {
+	ctx := context.Background()
+	repo := inmemory.NewPortRepository()
+
+	port := domain.Port{Name: "Port 1", City: "City 1", Country: "Country 1", UNLOC: "PORT1"}
+	_, err := repo.UpsertPort(ctx, port)
+	assert.NoError(t, err, "Expected no error")
+
+	hashes, err := repo.GetPortHashes(ctx, []domain.UNLOCODE{"PORT2", "PORT1"})
+	assert.NoError(t, err, "Expected no error")
+	assert.Equal(t, []string{"", port.Hash()}, hashes, "Expected the hash of the stored port only")
+}
+
+func TestInMemoryPortRepository_DeletePorts(t *testing.T) {
+	ctx := context.Background()
+	repo := inmemory.NewPortRepository()
+
+	ports := []domain.Port{
+		{Name: "Port 1", City: "City 1", Country: "Country 1", UNLOC: "PORT1"},
+		{Name: "Port 2", City: "City 2", Country: "Country 2", UNLOC: "PORT2"},
+	}
+	repo.UpsertPorts(ctx, ports)
+
+	err := repo.ScanUNLOCs(ctx, func(unlocs []domain.UNLOCODE) error {
+		assert.ElementsMatch(t, []domain.UNLOCODE{"PORT1", "PORT2"}, unlocs, "Expected the UNLOCs of the stored ports")
+		return repo.DeletePorts(ctx, []domain.UNLOCODE{"PORT1", "PORT3"})
+	})
+	assert.NoError(t, err, "Expected no error")
+
+	port, err := repo.GetPortByUNLOC(ctx, "PORT1")
+	assert.NoError(t, err, "Expected no error")
+	assert.Nil(t, port, "Expected the port to be deleted")
+	assert.Equal(t, 1, repo.GetPortsLength(ctx), "Expected 1 port in the repository")
+}
+
+func TestInMemoryPortRepository_Checkpoints(t *testing.T) {
+	ctx := context.Background()
+	repo := inmemory.NewPortRepository()
+
+	result, err := repo.GetCheckpoint(ctx, "ports.json")
+	assert.NoError(t, err, "Expected no error")
+	assert.Nil(t, result, "Expected a nil checkpoint")
+
+	checkpoint := domain.Checkpoint{Source: "ports.json", Fingerprint: "fingerprint", Offset: 42}
+	err = repo.SaveCheckpoint(ctx, checkpoint)
+	assert.NoError(t, err, "Expected no error")
+
+	result, err = repo.GetCheckpoint(ctx, "ports.json")
+	assert.NoError(t, err, "Expected no error")
+	assert.Equal(t, &checkpoint, result, "Expected checkpoint to match")
+
+	err = repo.DeleteCheckpoint(ctx, "ports.json")
+	assert.NoError(t, err, "Expected no error")
+
+	result, err = repo.GetCheckpoint(ctx, "ports.json")
+	assert.NoError(t, err, "Expected no error")
+	assert.Nil(t, result, "Expected a nil checkpoint")
+}
+
+func TestInMemoryPortRepository_Provenance(t *testing.T) {
+	ctx := context.Background()
+	repo := inmemory.NewPortRepository()
+
+	port := domain.Port{Name: "Port 1", City: "City 1", Country: "Country 1", UNLOC: "PORT1"}
+	origin := domain.Origin{Source: "ports.json", RunID: "run-1", Offset: 42, ChangedAt: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+
+	provenance, err := repo.GetProvenance(ctx, port.UNLOC)
+	assert.NoError(t, err, "Expected no error")
+	assert.Nil(t, provenance, "Expected no provenance")
+
+	_, err = repo.UpsertPort(ctx, port)
+	assert.NoError(t, err, "Expected no error")
+	err = repo.RecordProvenance(ctx, []domain.Port{port}, []domain.Origin{origin})
+	assert.NoError(t, err, "Expected no error")
+
+	provenance, err = repo.GetProvenance(ctx, port.UNLOC)
+	assert.NoError(t, err, "Expected no error")
+	assert.NotNil(t, provenance, "Expected a non-nil provenance")
+	assert.Equal(t, origin, provenance.Origin, "Expected the origin of the port")
+	assert.Equal(t, origin, provenance.Fields["name"].Origin, "Expected the origin of the name")
+
+	err = repo.DeletePorts(ctx, []domain.UNLOCODE{port.UNLOC})
+	assert.NoError(t, err, "Expected no error")
+	provenance, err = repo.GetProvenance(ctx, port.UNLOC)
+	assert.NoError(t, err, "Expected no error")
+	assert.Nil(t, provenance, "Expected the provenance to be deleted along with the port")
+}
@@ -4,11 +4,45 @@ import (
 	"context"
 	"encoding/json"
 	"ports-service/internal/ports/domain"
+	"strings"
 
 	"github.com/go-redis/redis/v8"
 )
 
-const portPrefix = "port:"
+// maxProvenanceAttempts is the number of times provenance is recorded when it keeps being changed
+// concurrently by other imports.
+const maxProvenanceAttempts = 5
+
+// scanCount is the number of keys Redis is hinted to go through at each step of a scan.
+const scanCount = 1000
+
+const (
+	portPrefix       = "port:"
+	checkpointPrefix = "checkpoint:"
+	provenancePrefix = "provenance:"
+	// portHashesKey is the hash mapping the UNLOC of each stored port to its domain.Port Hash.
+	portHashesKey = "port-hashes"
+)
+
+// upsertScript stores a port and its hash unless it is already identical to the stored one.
+// KEYS are the port key and portHashesKey, ARGV the port, its hash and its UNLOC.
+// Ports stored before their hash are compared as is, and get their hash stored along the way.
+// It returns the resulting domain.UpsertStatus: 1 when inserted, 2 when updated and 3 when unchanged.
+var upsertScript = redis.NewScript(`
+if redis.call('HGET', KEYS[2], ARGV[3]) == ARGV[2] and redis.call('EXISTS', KEYS[1]) == 1 then
+	return 3
+end
+local current = redis.call('GET', KEYS[1])
+redis.call('HSET', KEYS[2], ARGV[3], ARGV[2])
+if current == ARGV[1] then
+	return 3
+end
+redis.call('SET', KEYS[1], ARGV[1])
+if current then
+	return 2
+end
+return 1
+`)
 
 // PortRepository is a Redis repository handling ports.
 type PortRepository struct {
@@ -29,25 +63,131 @@ func NewPortRepository(redisURL string) (*PortRepository, error) {
 }
 
 // UpsertPort inserts or updates a port in the repository.
-func (r *PortRepository) UpsertPort(ctx context.Context, port domain.Port) error {
+// It reports whether the port was inserted, updated or left unchanged.
+func (r *PortRepository) UpsertPort(ctx context.Context, port domain.Port) (domain.UpsertStatus, error) {
 	data, err := json.Marshal(port)
 	if err != nil {
-		return err
+		return 0, err
 	}
 
-	key := portPrefix + port.UNLOC
-	err = r.client.Set(ctx, key, data, 0).Err()
+	keys := []string{portKey(port.UNLOC), portHashesKey}
+	status, err := upsertScript.Run(ctx, r.client, keys, data, port.Hash(), string(port.UNLOC)).Int()
 	if err != nil {
+		return 0, err
+	}
+
+	return domain.UpsertStatus(status), nil
+}
+
+// UpsertPortIf inserts or updates a port in the repository, provided that the stored port is still
+// the previous one, nil meaning that it is not stored. It returns domain.ErrPortChanged otherwise.
+// The port is watched from the time it is compared until it is written, so that concurrent writes
+// in between make it fail as well.
+func (r *PortRepository) UpsertPortIf(ctx context.Context, port domain.Port, previous *domain.Port) (domain.UpsertStatus, error) {
+	data, err := json.Marshal(port)
+	if err != nil {
+		return 0, err
+	}
+
+	key := portKey(port.UNLOC)
+	var status domain.UpsertStatus
+	err = r.client.Watch(ctx, func(tx *redis.Tx) error {
+		current, err := tx.Get(ctx, key).Bytes()
+		switch {
+		case err == redis.Nil:
+			if previous != nil {
+				return domain.ErrPortChanged
+			}
+			status = domain.Inserted
+		case err != nil:
+			return err
+		default:
+			var stored domain.Port
+			if err := json.Unmarshal(current, &stored); err != nil {
+				return err
+			}
+			if previous == nil || stored.Hash() != previous.Hash() {
+				return domain.ErrPortChanged
+			}
+			if stored.Hash() == port.Hash() {
+				status = domain.Unchanged
+				return nil
+			}
+			status = domain.Updated
+		}
+
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.Set(ctx, key, data, 0)
+			pipe.HSet(ctx, portHashesKey, string(port.UNLOC), port.Hash())
+			return nil
+		})
 		return err
+	}, key)
+	if err == redis.TxFailedErr {
+		return 0, domain.ErrPortChanged
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	return status, nil
+}
+
+// UpsertPorts inserts or updates several ports in the repository using a single pipeline.
+// It returns one result per port, so a failed write does not hide the outcome of the others.
+func (r *PortRepository) UpsertPorts(ctx context.Context, ports []domain.Port) []domain.UpsertResult {
+	results := make([]domain.UpsertResult, len(ports))
+
+	var (
+		indexes []int
+		written []domain.Port
+		values  [][]byte
+	)
+	for i, port := range ports {
+		data, err := json.Marshal(port)
+		if err != nil {
+			results[i].Err = err
+			continue
+		}
+		indexes = append(indexes, i)
+		written = append(written, port)
+		values = append(values, data)
+	}
+
+	cmds := r.runUpsertPipeline(ctx, written, values)
+	if len(cmds) > 0 && isNoScript(cmds[0].Err()) {
+		// The script is not cached by Redis yet, load it once and retry the whole pipeline
+		if err := upsertScript.Load(ctx, r.client).Err(); err == nil {
+			cmds = r.runUpsertPipeline(ctx, written, values)
+		}
 	}
 
-	return nil
+	for i, cmd := range cmds {
+		status, err := cmd.Int()
+		results[indexes[i]] = domain.UpsertResult{Status: domain.UpsertStatus(status), Err: err}
+	}
+
+	return results
+}
+
+// runUpsertPipeline runs the upsert script for each port and its marshalled value in a single pipeline.
+// Exec only returns the first failure, so the outcome of each write is read from its command.
+func (r *PortRepository) runUpsertPipeline(ctx context.Context, ports []domain.Port, values [][]byte) []*redis.Cmd {
+	cmds := make([]*redis.Cmd, len(ports))
+
+	pipe := r.client.Pipeline()
+	for i, port := range ports {
+		keys := []string{portKey(port.UNLOC), portHashesKey}
+		cmds[i] = upsertScript.EvalSha(ctx, pipe, keys, values[i], port.Hash(), string(port.UNLOC))
+	}
+	_, _ = pipe.Exec(ctx)
+
+	return cmds
 }
 
 // GetPortByUNLOC retrieves a port from the repository by its UNLOC code.
-func (r *PortRepository) GetPortByUNLOC(ctx context.Context, unloc string) (*domain.Port, error) {
-	key := portPrefix + unloc
-	data, err := r.client.Get(ctx, key).Bytes()
+func (r *PortRepository) GetPortByUNLOC(ctx context.Context, unloc domain.UNLOCODE) (*domain.Port, error) {
+	data, err := r.client.Get(ctx, portKey(unloc)).Bytes()
 	if err != nil {
 		if err == redis.Nil {
 			return nil, nil
@@ -64,6 +204,147 @@ func (r *PortRepository) GetPortByUNLOC(ctx context.Context, unloc string) (*dom
 	return &port, nil
 }
 
+// DeletePorts removes the ports with the given UNLOCs along with their hashes and provenance, ignoring the ones not stored.
+func (r *PortRepository) DeletePorts(ctx context.Context, unlocs []domain.UNLOCODE) error {
+	if len(unlocs) == 0 {
+		return nil
+	}
+
+	keys := make([]string, 0, 2*len(unlocs))
+	for _, unloc := range unlocs {
+		keys = append(keys, portKey(unloc), provenanceKey(unloc))
+	}
+
+	pipe := r.client.TxPipeline()
+	pipe.Del(ctx, keys...)
+	pipe.HDel(ctx, portHashesKey, hashFields(unlocs)...)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// ScanUNLOCs calls fn with the UNLOCs of the stored ports, a page at a time.
+// It relies on SCAN, so that Redis is not blocked however many ports are stored, and
+// ports may be reported more than once.
+func (r *PortRepository) ScanUNLOCs(ctx context.Context, fn func(unlocs []domain.UNLOCODE) error) error {
+	var cursor uint64
+	for {
+		keys, next, err := r.client.Scan(ctx, cursor, portPrefix+"*", scanCount).Result()
+		if err != nil {
+			return err
+		}
+
+		if len(keys) > 0 {
+			unlocs := make([]domain.UNLOCODE, len(keys))
+			for i, key := range keys {
+				unlocs[i] = domain.UNLOCODE(strings.TrimPrefix(key, portPrefix))
+			}
+			if err := fn(unlocs); err != nil {
+				return err
+			}
+		}
+
+		if next == 0 {
+			return nil
+		}
+		cursor = next
+	}
+}
+
+// GetPortHashes returns the hashes of the ports stored with the given UNLOCs, in the same order,
+// and an empty hash for the ports not stored.
+func (r *PortRepository) GetPortHashes(ctx context.Context, unlocs []domain.UNLOCODE) ([]string, error) {
+	values, err := r.client.HMGet(ctx, portHashesKey, hashFields(unlocs)...).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	hashes := make([]string, len(values))
+	for i, value := range values {
+		// Missing fields are returned as nil
+		hashes[i], _ = value.(string)
+	}
+	return hashes, nil
+}
+
+// GetProvenance retrieves the provenance of the port stored with the given UNLOC.
+func (r *PortRepository) GetProvenance(ctx context.Context, unloc domain.UNLOCODE) (*domain.Provenance, error) {
+	data, err := r.client.Get(ctx, provenanceKey(unloc)).Bytes()
+	if err != nil {
+		if err == redis.Nil {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var provenance domain.Provenance
+	err = json.Unmarshal(data, &provenance)
+	if err != nil {
+		return nil, err
+	}
+
+	return &provenance, nil
+}
+
+// RecordProvenance updates the provenance of the given ports, written by the origins of the same index.
+// The provenance is watched from the time it is read until it is written, and recorded again when
+// another import changed it in between.
+func (r *PortRepository) RecordProvenance(ctx context.Context, ports []domain.Port, origins []domain.Origin) error {
+	keys := make([]string, len(ports))
+	for i, port := range ports {
+		keys[i] = provenanceKey(port.UNLOC)
+	}
+
+	var err error
+	for attempt := 0; attempt < maxProvenanceAttempts; attempt++ {
+		err = r.client.Watch(ctx, func(tx *redis.Tx) error {
+			return recordProvenance(ctx, tx, keys, ports, origins)
+		}, keys...)
+		if err != redis.TxFailedErr {
+			return err
+		}
+	}
+	return err
+}
+
+// recordProvenance reads the provenance of the ports, and writes the ones the origins change in a transaction.
+func recordProvenance(ctx context.Context, tx *redis.Tx, keys []string, ports []domain.Port, origins []domain.Origin) error {
+	values, err := tx.MGet(ctx, keys...).Result()
+	if err != nil {
+		return err
+	}
+
+	// The same port may be found several times, each update applying to the previous one
+	updated := make(map[string]domain.Provenance, len(keys))
+	changed := make(map[string]bool, len(keys))
+	for i, value := range values {
+		provenance, seen := updated[keys[i]]
+		if data, ok := value.(string); ok && !seen {
+			if err := json.Unmarshal([]byte(data), &provenance); err != nil {
+				return err
+			}
+		}
+		var change bool
+		if updated[keys[i]], change = provenance.Update(ports[i], origins[i]); change {
+			changed[keys[i]] = true
+		}
+	}
+	if len(changed) == 0 {
+		return nil
+	}
+
+	_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		for key := range changed {
+			data, err := json.Marshal(updated[key])
+			if err != nil {
+				return err
+			}
+			pipe.Set(ctx, key, data, 0)
+		}
+		return nil
+	})
+	return err
+}
+
 // GetPortsLength returns the total number of ports in the repository.
 func (r *PortRepository) GetPortsLength(ctx context.Context) (int64, error) {
 	keys, err := r.client.Keys(ctx, portPrefix+"*").Result()
@@ -73,3 +354,61 @@ func (r *PortRepository) GetPortsLength(ctx context.Context) (int64, error) {
 
 	return int64(len(keys)), nil
 }
+
+// GetCheckpoint retrieves the import checkpoint of the given source.
+func (r *PortRepository) GetCheckpoint(ctx context.Context, source string) (*domain.Checkpoint, error) {
+	data, err := r.client.Get(ctx, checkpointPrefix+source).Bytes()
+	if err != nil {
+		if err == redis.Nil {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var checkpoint domain.Checkpoint
+	err = json.Unmarshal(data, &checkpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	return &checkpoint, nil
+}
+
+// SaveCheckpoint inserts or updates the import checkpoint of a source.
+func (r *PortRepository) SaveCheckpoint(ctx context.Context, checkpoint domain.Checkpoint) error {
+	data, err := json.Marshal(checkpoint)
+	if err != nil {
+		return err
+	}
+
+	return r.client.Set(ctx, checkpointPrefix+checkpoint.Source, data, 0).Err()
+}
+
+// DeleteCheckpoint removes the import checkpoint of the given source.
+func (r *PortRepository) DeleteCheckpoint(ctx context.Context, source string) error {
+	return r.client.Del(ctx, checkpointPrefix+source).Err()
+}
+
+// portKey returns the key of the port with the given UNLOC.
+func portKey(unloc domain.UNLOCODE) string {
+	return portPrefix + string(unloc)
+}
+
+// provenanceKey returns the key of the provenance of the port with the given UNLOC.
+func provenanceKey(unloc domain.UNLOCODE) string {
+	return provenancePrefix + string(unloc)
+}
+
+// hashFields returns the fields of portHashesKey holding the hashes of the ports with the given UNLOCs.
+func hashFields(unlocs []domain.UNLOCODE) []string {
+	fields := make([]string, len(unlocs))
+	for i, unloc := range unlocs {
+		fields[i] = string(unloc)
+	}
+	return fields
+}
+
+// isNoScript reports whether the error means that Redis does not know the script being run.
+func isNoScript(err error) bool {
+	return err != nil && strings.HasPrefix(err.Error(), "NOSCRIPT")
+}
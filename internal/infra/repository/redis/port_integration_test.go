@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/testcontainers/testcontainers-go"
@@ -82,8 +83,9 @@ func TestRedisPortRepository_UpsertPort(t *testing.T) {
 		UNLOC:   "TEST",
 	}
 
-	err = redisRepo.UpsertPort(ctx, port)
+	status, err := redisRepo.UpsertPort(ctx, port)
 	assert.NoError(t, err, "Expected no error")
+	assert.Equal(t, domain.Inserted, status, "Expected port to be inserted")
 
 	result, err := redisRepo.GetPortByUNLOC(ctx, "TEST")
 	assert.NoError(t, err, "Expected no error")
@@ -110,7 +112,7 @@ func TestRedisPortRepository_GetPortByUNLOC(t *testing.T) {
 		Country: "Test Country",
 		UNLOC:   "TEST",
 	}
-	err = redisRepo.UpsertPort(ctx, port)
+	_, err = redisRepo.UpsertPort(ctx, port)
 	assert.NoError(t, err, "Expected no error")
 
 	result, err = redisRepo.GetPortByUNLOC(ctx, "TEST")
@@ -134,7 +136,7 @@ func TestRedisPortRepository_GetPortsLength(t *testing.T) {
 		Country: "Country 1",
 		UNLOC:   "PORT1",
 	}
-	err = redisRepo.UpsertPort(ctx, port1)
+	_, err = redisRepo.UpsertPort(ctx, port1)
 	assert.NoError(t, err, "Expected no error")
 
 	port2 := domain.Port{
@@ -143,7 +145,7 @@ func TestRedisPortRepository_GetPortsLength(t *testing.T) {
 		Country: "Country 2",
 		UNLOC:   "PORT2",
 	}
-	err = redisRepo.UpsertPort(ctx, port2)
+	_, err = redisRepo.UpsertPort(ctx, port2)
 	assert.NoError(t, err, "Expected no error")
 
 	// Get the total number of ports in the repository
@@ -158,7 +160,7 @@ func TestRedisPortRepository_GetPortsLength(t *testing.T) {
 		Country: "Country 3",
 		UNLOC:   "PORT3",
 	}
-	err = redisRepo.UpsertPort(ctx, port3)
+	_, err = redisRepo.UpsertPort(ctx, port3)
 	assert.NoError(t, err, "Expected no error")
 
 	// Get the updated total number of ports in the repository
@@ -166,3 +168,182 @@ func TestRedisPortRepository_GetPortsLength(t *testing.T) {
 	assert.NoError(t, err, "Expected no error")
 	assert.Equal(t, int64(3), length, "Expected 3 ports in the repository")
 }
+
+func TestRedisPortRepository_UpsertPorts(t *testing.T) {
+	ctx := context.Background()
+	cleanup, err := setupRedisContainer(t)
+	assert.NoError(t, err, "Failed to set up Redis container")
+	defer cleanup()
+
+	ports := []domain.Port{
+		{Name: "Port 1", City: "City 1", Country: "Country 1", UNLOC: "PORT1"},
+		{Name: "Port 2", City: "City 2", Country: "Country 2", UNLOC: "PORT2"},
+	}
+
+	results := redisRepo.UpsertPorts(ctx, ports)
+	assert.Equal(t, []domain.UpsertResult{{Status: domain.Inserted}, {Status: domain.Inserted}}, results, "Expected ports to be inserted")
+
+	ports[1].Name = "Port 2 renamed"
+	results = redisRepo.UpsertPorts(ctx, ports)
+	assert.Equal(t, []domain.UpsertResult{{Status: domain.Unchanged}, {Status: domain.Updated}}, results, "Expected only the renamed port to be updated")
+
+	for _, port := range ports {
+		result, err := redisRepo.GetPortByUNLOC(ctx, port.UNLOC)
+		assert.NoError(t, err, "Expected no error")
+		assert.NotNil(t, result, "Expected a non-nil port")
+		assert.Equal(t, port.Name, result.Name, "Expected port name to match")
+	}
+
+	length, err := redisRepo.GetPortsLength(ctx)
+	assert.NoError(t, err, "Expected no error")
+	assert.Equal(t, int64(2), length, "Expected 2 ports in the repository")
+}
+
+func TestRedisPortRepository_UpsertPortIf(t *testing.T) {
+	ctx := context.Background()
+	cleanup, err := setupRedisContainer(t)
+	assert.NoError(t, err, "Failed to set up Redis container")
+	defer cleanup()
+
+	port := domain.Port{Name: "Port 1", City: "City 1", Country: "Country 1", UNLOC: "PORT1"}
+	var status domain.UpsertStatus
+	status, err = redisRepo.UpsertPortIf(ctx, port, nil)
+	assert.NoError(t, err, "Expected no error")
+	assert.Equal(t, domain.Inserted, status, "Expected port to be inserted")
+
+	// The port is stored by now, so it is not written as if it were not
+	_, err = redisRepo.UpsertPortIf(ctx, port, nil)
+	assert.ErrorIs(t, err, domain.ErrPortChanged, "Expected the port to have changed")
+
+	renamed := port
+	renamed.Name = "Port 1 renamed"
+	status, err = redisRepo.UpsertPortIf(ctx, renamed, &port)
+	assert.NoError(t, err, "Expected no error")
+	assert.Equal(t, domain.Updated, status, "Expected port to be updated")
+
+	// The previous port is stale once renamed
+	_, err = redisRepo.UpsertPortIf(ctx, port, &port)
+	assert.ErrorIs(t, err, domain.ErrPortChanged, "Expected the port to have changed")
+
+	status, err = redisRepo.UpsertPortIf(ctx, renamed, &renamed)
+	assert.NoError(t, err, "Expected no error")
+	assert.Equal(t, domain.Unchanged, status, "Expected port to be unchanged")
+
+	result, err := redisRepo.GetPortByUNLOC(ctx, port.UNLOC)
+	assert.NoError(t, err, "Expected no error")
+	assert.Equal(t, renamed.Name, result.Name, "Expected port name to match")
+}
+
+func TestRedisPortRepository_GetPortHashes(t *testing.T) {
+	ctx := context.Background()
+	cleanup, err := setupRedisContainer(t)
+	assert.NoError(t, err, "Failed to set up Redis container")
+	defer cleanup()
+
+	port := domain.Port{Name: "Port 1", City: "City 1", Country: "Country 1", UNLOC: "PORT1"}
+	_, err = redisRepo.UpsertPort(ctx, port)
+	assert.NoError(t, err, "Expected no error")
+
+	hashes, err := redisRepo.GetPortHashes(ctx, []domain.UNLOCODE{"PORT2", "PORT1"})
+	assert.NoError(t, err, "Expected no error")
+	assert.Equal(t, []string{"", port.Hash()}, hashes, "Expected the hash of the stored port only")
+
+	// The hashes are not counted as ports
+	length, err := redisRepo.GetPortsLength(ctx)
+	assert.NoError(t, err, "Expected no error")
+	assert.Equal(t, int64(1), length, "Expected 1 port in the repository")
+}
+
+func TestRedisPortRepository_DeletePorts(t *testing.T) {
+	ctx := context.Background()
+	cleanup, err := setupRedisContainer(t)
+	assert.NoError(t, err, "Failed to set up Redis container")
+	defer cleanup()
+
+	ports := []domain.Port{
+		{Name: "Port 1", City: "City 1", Country: "Country 1", UNLOC: "PORT1"},
+		{Name: "Port 2", City: "City 2", Country: "Country 2", UNLOC: "PORT2"},
+	}
+	redisRepo.UpsertPorts(ctx, ports)
+
+	var scanned []domain.UNLOCODE
+	err = redisRepo.ScanUNLOCs(ctx, func(unlocs []domain.UNLOCODE) error {
+		scanned = append(scanned, unlocs...)
+		return nil
+	})
+	assert.NoError(t, err, "Expected no error")
+	assert.ElementsMatch(t, []domain.UNLOCODE{"PORT1", "PORT2"}, scanned, "Expected the UNLOCs of the stored ports")
+
+	err = redisRepo.DeletePorts(ctx, []domain.UNLOCODE{"PORT1", "PORT3"})
+	assert.NoError(t, err, "Expected no error")
+
+	port, err := redisRepo.GetPortByUNLOC(ctx, "PORT1")
+	assert.NoError(t, err, "Expected no error")
+	assert.Nil(t, port, "Expected the port to be deleted")
+
+	hashes, err := redisRepo.GetPortHashes(ctx, []domain.UNLOCODE{"PORT1", "PORT2"})
+	assert.NoError(t, err, "Expected no error")
+	assert.Equal(t, []string{"", ports[1].Hash()}, hashes, "Expected the hash of the deleted port to be deleted")
+}
+
+func TestRedisPortRepository_Checkpoints(t *testing.T) {
+	ctx := context.Background()
+	cleanup, err := setupRedisContainer(t)
+	assert.NoError(t, err, "Failed to set up Redis container")
+	defer cleanup()
+
+	result, err := redisRepo.GetCheckpoint(ctx, "ports.json")
+	assert.NoError(t, err, "Expected no error")
+	assert.Nil(t, result, "Expected a nil checkpoint")
+
+	checkpoint := domain.Checkpoint{Source: "ports.json", Fingerprint: "fingerprint", Offset: 42}
+	err = redisRepo.SaveCheckpoint(ctx, checkpoint)
+	assert.NoError(t, err, "Expected no error")
+
+	result, err = redisRepo.GetCheckpoint(ctx, "ports.json")
+	assert.NoError(t, err, "Expected no error")
+	assert.Equal(t, &checkpoint, result, "Expected checkpoint to match")
+
+	err = redisRepo.DeleteCheckpoint(ctx, "ports.json")
+	assert.NoError(t, err, "Expected no error")
+
+	result, err = redisRepo.GetCheckpoint(ctx, "ports.json")
+	assert.NoError(t, err, "Expected no error")
+	assert.Nil(t, result, "Expected a nil checkpoint")
+
+	// Checkpoints are not counted as ports
+	length, err := redisRepo.GetPortsLength(ctx)
+	assert.NoError(t, err, "Expected no error")
+	assert.Zero(t, length, "Expected no ports in the repository")
+}
+
+func TestRedisPortRepository_Provenance(t *testing.T) {
+	ctx := context.Background()
+	cleanup, err := setupRedisContainer(t)
+	assert.NoError(t, err, "Failed to set up Redis container")
+	defer cleanup()
+
+	port := domain.Port{Name: "Port 1", City: "City 1", Country: "Country 1", UNLOC: "PORT1"}
+	origin := domain.Origin{Source: "ports.json", RunID: "run-1", Offset: 42, ChangedAt: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+
+	provenance, err := redisRepo.GetProvenance(ctx, port.UNLOC)
+	assert.NoError(t, err, "Expected no error")
+	assert.Nil(t, provenance, "Expected no provenance")
+
+	_, err = redisRepo.UpsertPort(ctx, port)
+	assert.NoError(t, err, "Expected no error")
+	err = redisRepo.RecordProvenance(ctx, []domain.Port{port}, []domain.Origin{origin})
+	assert.NoError(t, err, "Expected no error")
+
+	provenance, err = redisRepo.GetProvenance(ctx, port.UNLOC)
+	assert.NoError(t, err, "Expected no error")
+	assert.NotNil(t, provenance, "Expected a non-nil provenance")
+	assert.Equal(t, origin, provenance.Origin, "Expected the origin of the port")
+	assert.Equal(t, origin, provenance.Fields["name"].Origin, "Expected the origin of the name")
+
+	err = redisRepo.DeletePorts(ctx, []domain.UNLOCODE{port.UNLOC})
+	assert.NoError(t, err, "Expected no error")
+	provenance, err = redisRepo.GetProvenance(ctx, port.UNLOC)
+	assert.NoError(t, err, "Expected no error")
+	assert.Nil(t, provenance, "Expected the provenance to be deleted along with the port")
+}
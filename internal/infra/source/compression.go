@@ -0,0 +1,46 @@
+package source
+
+import (
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"io"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// zstdMaxMemory caps the memory used by the zstd decompression, whatever the window size of the content.
+const zstdMaxMemory = 64 << 20
+
+var (
+	gzipMagic  = []byte{0x1f, 0x8b}
+	bzip2Magic = []byte("BZh")
+	zstdMagic  = []byte{0x28, 0xb5, 0x2f, 0xfd}
+)
+
+// Decompress returns a reader decompressing the content as a stream when it is compressed
+// with gzip, bzip2 or zstd, as detected from its magic bytes, and the content as is otherwise.
+// Closing the reader releases the decompression resources, but not the content.
+func Decompress(content io.Reader) (io.ReadCloser, error) {
+	buffered := bufio.NewReader(content)
+	magic, err := buffered.Peek(len(zstdMagic))
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	switch {
+	case bytes.HasPrefix(magic, gzipMagic):
+		return gzip.NewReader(buffered)
+	case bytes.HasPrefix(magic, bzip2Magic):
+		return io.NopCloser(bzip2.NewReader(buffered)), nil
+	case bytes.HasPrefix(magic, zstdMagic):
+		dec, err := zstd.NewReader(buffered, zstd.WithDecoderConcurrency(1), zstd.WithDecoderMaxMemory(zstdMaxMemory))
+		if err != nil {
+			return nil, err
+		}
+		return dec.IOReadCloser(), nil
+	default:
+		return io.NopCloser(buffered), nil
+	}
+}
@@ -0,0 +1,75 @@
+package source_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/assert"
+
+	"ports-service/internal/infra/source"
+)
+
+const samplePorts = `{"AEJEA": {"name": "Jebel Ali"}}`
+
+func TestDecompress(t *testing.T) {
+	var gzipped bytes.Buffer
+	gzipWriter := gzip.NewWriter(&gzipped)
+	_, err := gzipWriter.Write([]byte(samplePorts))
+	assert.NoError(t, err)
+	assert.NoError(t, gzipWriter.Close())
+
+	var zstdCompressed bytes.Buffer
+	zstdWriter, err := zstd.NewWriter(&zstdCompressed)
+	assert.NoError(t, err)
+	_, err = zstdWriter.Write([]byte(samplePorts))
+	assert.NoError(t, err)
+	assert.NoError(t, zstdWriter.Close())
+
+	tests := []struct {
+		name    string
+		content []byte
+	}{
+		{
+			name:    "Uncompressed",
+			content: []byte(samplePorts),
+		},
+		{
+			name:    "Gzip",
+			content: gzipped.Bytes(),
+		},
+		{
+			// Compressed with the bzip2 command line, as the standard library cannot compress it
+			name: "Bzip2",
+			content: []byte("\x42\x5a\x68\x39\x31\x41\x59\x26\x53\x59\x9a\xbe\x3a\x19\x00\x00\x0e\x1d\x80\x50\x00\x00\x10\x22" +
+				"\x10\x32\x27\x00\x0a\x20\x00\x22\x99\x3d\x13\xd2\x69\xea\x0a\x60\x00\x1f\x8d\x50\xa1\xaf\x78\x2c\x2a\x40" +
+				"\x45\x4a\xe8\x28\xfa\xd9\xfe\x2e\xe4\x8a\x70\xa1\x21\x35\x7c\x74\x32"),
+		},
+		{
+			name:    "Zstd",
+			content: zstdCompressed.Bytes(),
+		},
+		{
+			name:    "Empty",
+			content: []byte{},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			reader, err := source.Decompress(bytes.NewReader(test.content))
+			assert.NoError(t, err)
+			defer reader.Close()
+
+			data, err := io.ReadAll(reader)
+			assert.NoError(t, err)
+			if len(test.content) == 0 {
+				assert.Empty(t, data)
+			} else {
+				assert.Equal(t, samplePorts, string(data))
+			}
+		})
+	}
+}
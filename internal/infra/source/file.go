@@ -0,0 +1,21 @@
+// Package source provides access to the files ports are imported from.
+package source
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+)
+
+// Fingerprint returns the SHA-256 checksum of the content, and rewinds it to its start.
+func Fingerprint(content io.ReadSeeker) (string, error) {
+	h := sha256.New()
+	if _, err := io.Copy(h, content); err != nil {
+		return "", err
+	}
+	if _, err := content.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,28 @@
+package source_test
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"ports-service/internal/infra/source"
+)
+
+func TestFingerprint(t *testing.T) {
+	content := strings.NewReader(`{"AEJEA": {"name": "Jebel Ali"}}`)
+
+	fingerprint, err := source.Fingerprint(content)
+	assert.NoError(t, err)
+	assert.Len(t, fingerprint, 64)
+
+	// The content is rewound so that it can be imported afterwards
+	data, err := io.ReadAll(content)
+	assert.NoError(t, err)
+	assert.Equal(t, `{"AEJEA": {"name": "Jebel Ali"}}`, string(data))
+
+	other, err := source.Fingerprint(strings.NewReader(`{"AEJEA": {"name": "Jebel Ali 2"}}`))
+	assert.NoError(t, err)
+	assert.NotEqual(t, fingerprint, other)
+}
@@ -0,0 +1,72 @@
+package source
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Stdin is the path standing for the standard input.
+const Stdin = "-"
+
+// ExpandPaths expands the given paths into the files to import, in a deterministic order: paths are kept in
+// the given order, glob patterns are replaced by their matches and directories by the files they hold, both in
+// name order, hidden files excluded. Files found several times are only kept the first time.
+// Stdin, paths which do not exist and patterns matching nothing are kept as is, so that opening them tells why.
+func ExpandPaths(paths []string) ([]string, error) {
+	var files []string
+	seen := make(map[string]bool)
+	keep := func(path string) {
+		if !seen[path] {
+			seen[path] = true
+			files = append(files, path)
+		}
+	}
+
+	for _, path := range paths {
+		if path == Stdin {
+			keep(path)
+			continue
+		}
+
+		matches := []string{path}
+		if strings.ContainsAny(path, "*?[") {
+			var err error
+			if matches, err = filepath.Glob(path); err != nil {
+				return nil, err
+			}
+			if len(matches) == 0 {
+				keep(path)
+				continue
+			}
+		}
+
+		for _, match := range matches {
+			if match != path && isHidden(match) {
+				continue
+			}
+			info, err := os.Stat(match)
+			if err != nil || !info.IsDir() {
+				keep(match)
+				continue
+			}
+
+			entries, err := os.ReadDir(match)
+			if err != nil {
+				return nil, err
+			}
+			// ReadDir sorts the entries by name
+			for _, entry := range entries {
+				if !entry.IsDir() && !isHidden(entry.Name()) {
+					keep(filepath.Join(match, entry.Name()))
+				}
+			}
+		}
+	}
+	return files, nil
+}
+
+// isHidden tells whether the file at the given path is hidden, such as the temporary files written by copy tools.
+func isHidden(path string) bool {
+	return strings.HasPrefix(filepath.Base(path), ".")
+}
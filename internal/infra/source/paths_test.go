@@ -0,0 +1,64 @@
+package source_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"ports-service/internal/infra/source"
+)
+
+func TestExpandPaths(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"ports-2.json", "ports-1.json", ".ports-3.json.tmp", "extra.ndjson", "nested/ports.json"} {
+		path := filepath.Join(dir, name)
+		assert.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		assert.NoError(t, os.WriteFile(path, []byte("{}"), 0o644))
+	}
+	nested := filepath.Join(dir, "nested")
+
+	tests := []struct {
+		name  string
+		paths []string
+		want  []string
+	}{
+		{
+			name:  "files in the given order",
+			paths: []string{filepath.Join(dir, "ports-2.json"), source.Stdin, filepath.Join(dir, "ports-1.json")},
+			want:  []string{filepath.Join(dir, "ports-2.json"), source.Stdin, filepath.Join(dir, "ports-1.json")},
+		},
+		{
+			name:  "glob matches in name order",
+			paths: []string{filepath.Join(dir, "ports-*.json")},
+			want:  []string{filepath.Join(dir, "ports-1.json"), filepath.Join(dir, "ports-2.json")},
+		},
+		{
+			name:  "directory files in name order",
+			paths: []string{dir, nested},
+			want: []string{
+				filepath.Join(dir, "extra.ndjson"), filepath.Join(dir, "ports-1.json"), filepath.Join(dir, "ports-2.json"),
+				filepath.Join(nested, "ports.json"),
+			},
+		},
+		{
+			name:  "files found several times kept once",
+			paths: []string{filepath.Join(dir, "ports-2.json"), filepath.Join(dir, "*.json")},
+			want:  []string{filepath.Join(dir, "ports-2.json"), filepath.Join(dir, "ports-1.json")},
+		},
+		{
+			name:  "missing files kept as is",
+			paths: []string{filepath.Join(dir, "missing.json"), filepath.Join(dir, "missing-*.json")},
+			want:  []string{filepath.Join(dir, "missing.json"), filepath.Join(dir, "missing-*.json")},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			files, err := source.ExpandPaths(test.paths)
+			assert.NoError(t, err)
+			assert.Equal(t, test.want, files)
+		})
+	}
+}
@@ -0,0 +1,168 @@
+package source
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// Watcher polls a file, or the files of a directory, for changes of their modification time, size or checksum.
+// Hidden files, such as the temporary files written by copy tools, are ignored.
+type Watcher struct {
+	path     string
+	interval time.Duration
+	settle   time.Duration
+	files    map[string]*watchedFile
+}
+
+// watchedFile is the state of a file known to a Watcher.
+type watchedFile struct {
+	size    int64
+	modTime time.Time
+	// changedAt is when the size or modification time were last seen changing.
+	changedAt time.Time
+	// pending is set while a change was not checksummed yet.
+	pending bool
+	// checksum is the checksum of the content last reported successfully.
+	checksum string
+}
+
+// NewWatcher returns a Watcher of the file or directory at the given path, polling it at the given interval.
+// A change is only reported once the size and modification time of the file did not change for the settle
+// duration, so that a file still being written is not reported.
+func NewWatcher(path string, interval, settle time.Duration) *Watcher {
+	return &Watcher{
+		path:     path,
+		interval: interval,
+		settle:   settle,
+		files:    make(map[string]*watchedFile),
+	}
+}
+
+// Watch calls fn with the path and checksum of every file found or changed, until the context is canceled.
+// The files found by the first poll are reported as well. Calls to fn never overlap, as they are made by
+// the polling loop: changes made in the meantime are reported by the next poll. A file for which fn fails
+// is reported again by the next polls until fn succeeds, even though it did not change.
+// A file whose content changed back and forth between two polls is not reported, as its checksum is the same.
+func (w *Watcher) Watch(ctx context.Context, fn func(path, checksum string) error) error {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		if err := w.poll(ctx, fn); err != nil {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// poll checks the watched files for changes, and reports the ones which settled.
+func (w *Watcher) poll(ctx context.Context, fn func(path, checksum string) error) error {
+	files, err := w.list()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	for path := range w.files {
+		if _, exists := files[path]; !exists {
+			delete(w.files, path)
+		}
+	}
+	// Files are reported in name order
+	paths := make([]string, 0, len(files))
+	for path := range files {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	for _, path := range paths {
+		info := files[path]
+		file, known := w.files[path]
+		if !known {
+			file = &watchedFile{}
+			w.files[path] = file
+		}
+		if !known || info.Size() != file.size || !info.ModTime().Equal(file.modTime) {
+			file.size = info.Size()
+			file.modTime = info.ModTime()
+			file.changedAt = now
+			file.pending = true
+		}
+		if !file.pending || now.Sub(file.changedAt) < w.settle {
+			continue
+		}
+
+		checksum, err := checksumFile(path)
+		if errors.Is(err, fs.ErrNotExist) {
+			// The file was removed since it was listed
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		// The file stays pending until fn succeeds, so that it is retried
+		if checksum == file.checksum || fn(path, checksum) == nil {
+			file.checksum = checksum
+			file.pending = false
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+// list returns the watched files by path, none when the watched path does not exist yet.
+func (w *Watcher) list() (map[string]fs.FileInfo, error) {
+	files := make(map[string]fs.FileInfo)
+	info, err := os.Stat(w.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return files, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		files[w.path] = info
+		return files, nil
+	}
+
+	entries, err := os.ReadDir(w.path)
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		if entry.IsDir() || isHidden(entry.Name()) {
+			continue
+		}
+		info, err := entry.Info()
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		files[filepath.Join(w.path, entry.Name())] = info
+	}
+	return files, nil
+}
+
+// checksumFile returns the Fingerprint of the file at the given path.
+func checksumFile(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	return Fingerprint(file)
+}
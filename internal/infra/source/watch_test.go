@@ -0,0 +1,102 @@
+package source_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"ports-service/internal/infra/source"
+)
+
+func TestWatcher_Watch(t *testing.T) {
+	dir := t.TempDir()
+	first := filepath.Join(dir, "ports.json")
+	assert.NoError(t, os.WriteFile(first, []byte(`{"AEJEA": {"name": "Jebel Ali"}}`), 0o644))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	changes := make(chan string, 10)
+	done := make(chan error)
+	go func() {
+		done <- source.NewWatcher(dir, 5*time.Millisecond, 20*time.Millisecond).Watch(ctx, func(path, checksum string) error {
+			assert.Len(t, checksum, 64)
+			changes <- path
+			return nil
+		})
+	}()
+
+	// The files found on start are reported
+	assert.Equal(t, first, nextChange(t, changes))
+
+	// New files are reported, unless hidden
+	second := filepath.Join(dir, "more-ports.json")
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, ".more-ports.json.tmp"), []byte(`{}`), 0o644))
+	assert.NoError(t, os.WriteFile(second, []byte(`{"AEJED": {"name": "Jebel Dhanna"}}`), 0o644))
+	assert.Equal(t, second, nextChange(t, changes))
+
+	// Files touched without changing their content are not reported
+	later := time.Now().Add(time.Minute)
+	assert.NoError(t, os.Chtimes(first, later, later))
+	select {
+	case path := <-changes:
+		t.Fatalf("unexpected change of %s", path)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	// Files whose content changed are reported
+	assert.NoError(t, os.WriteFile(first, []byte(`{"AEJEA": {"name": "Jebel Ali Port"}}`), 0o644))
+	assert.Equal(t, first, nextChange(t, changes))
+
+	cancel()
+	assert.ErrorIs(t, <-done, context.Canceled)
+}
+
+func TestWatcher_Watch_RetriesFailures(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ports.json")
+	assert.NoError(t, os.WriteFile(path, []byte(`{"AEJEA": {"name": "Jebel Ali"}}`), 0o644))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	changes := make(chan string, 10)
+	done := make(chan error)
+	failures := 2
+	go func() {
+		done <- source.NewWatcher(path, 5*time.Millisecond, 0).Watch(ctx, func(path, checksum string) error {
+			changes <- path
+			if failures > 0 {
+				failures--
+				return errors.New("import failed")
+			}
+			return nil
+		})
+	}()
+
+	// The unchanged file is reported until it is handled successfully, and no more
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, path, nextChange(t, changes))
+	}
+	select {
+	case path := <-changes:
+		t.Fatalf("unexpected change of %s", path)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	cancel()
+	assert.ErrorIs(t, <-done, context.Canceled)
+}
+
+// nextChange waits for the next change reported by a Watcher.
+func nextChange(t *testing.T, changes <-chan string) string {
+	t.Helper()
+
+	select {
+	case path := <-changes:
+		return path
+	case <-time.After(5 * time.Second):
+		t.Fatal("no change reported")
+		return ""
+	}
+}
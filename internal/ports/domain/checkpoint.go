@@ -0,0 +1,11 @@
+package domain
+
+// Checkpoint records how far an import of a source got, so that it can be resumed.
+type Checkpoint struct {
+	// Source identifies the imported source, e.g. its file path.
+	Source string `json:"source"`
+	// Fingerprint identifies the content of the source the checkpoint was taken on.
+	Fingerprint string `json:"fingerprint"`
+	// Offset is the byte offset in the source up to which all the ports were committed.
+	Offset int64 `json:"offset"`
+}
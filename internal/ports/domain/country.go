@@ -0,0 +1,153 @@
+package domain
+
+import (
+	_ "embed"
+	"encoding/csv"
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// Validation rules of the country of a port, reported on its Country field.
+const (
+	// RuleCountry is not satisfied by country names missing from the reference table and its aliases.
+	RuleCountry = "country"
+	// RuleCountryMismatch is not satisfied by country names of another country than the UNLOC's.
+	RuleCountryMismatch = "country_mismatch"
+)
+
+// iso3166CSV is the ISO 3166-1 reference table, one country per line with its alpha-2, alpha-3 and numeric
+// codes and its short name. It holds the Netherlands Antilles as well, withdrawn from ISO 3166-1 in 2010
+// but still the country of UN/LOCODE locations, and Kosovo, under the user-assigned code XK that UN/LOCODE
+// uses for it, with no numeric code.
+//
+//go:embed iso3166.csv
+var iso3166CSV string
+
+// countryAliasesCSV maps other names of the countries, official or common, to their alpha-2 code.
+//
+//go:embed country_aliases.csv
+var countryAliasesCSV string
+
+// Country is a country of the ISO 3166-1 reference table.
+type Country struct {
+	Alpha2  string
+	Alpha3  string
+	Numeric string
+	Name    string
+}
+
+// countries is the reference table, loaded once.
+var countries = loadCountries()
+
+// countryTable indexes the countries by alpha-2 code, and by folded name, alias and code, see foldCountryName.
+type countryTable struct {
+	all      []Country
+	byAlpha2 map[string]Country
+	byName   map[string]Country
+}
+
+// loadCountries loads the embedded reference table. It panics when the table is malformed,
+// which the tests would catch.
+func loadCountries() countryTable {
+	table := countryTable{byAlpha2: make(map[string]Country), byName: make(map[string]Country)}
+	for _, row := range readEmbeddedCSV(iso3166CSV, 4) {
+		country := Country{Alpha2: row[0], Alpha3: row[1], Numeric: row[2], Name: row[3]}
+		table.all = append(table.all, country)
+		table.byAlpha2[country.Alpha2] = country
+		for _, name := range []string{country.Alpha2, country.Alpha3, country.Name} {
+			table.byName[foldCountryName(name)] = country
+		}
+	}
+	for _, row := range readEmbeddedCSV(countryAliasesCSV, 2) {
+		country, ok := table.byAlpha2[row[1]]
+		if !ok {
+			panic(fmt.Sprintf("country alias %q of unknown code %q", row[0], row[1]))
+		}
+		table.byName[foldCountryName(row[0])] = country
+	}
+	return table
+}
+
+// readEmbeddedCSV returns the records of an embedded CSV file following its header.
+func readEmbeddedCSV(data string, fields int) [][]string {
+	reader := csv.NewReader(strings.NewReader(data))
+	reader.FieldsPerRecord = fields
+	records, err := reader.ReadAll()
+	if err != nil {
+		panic(fmt.Sprintf("invalid embedded CSV: %v", err))
+	}
+	return records[1:]
+}
+
+// diacritics replaces the accented letters found in country names with their base letter.
+var diacritics = strings.NewReplacer(
+	"à", "a", "á", "a", "â", "a", "ã", "a", "ä", "a", "å", "a", "ç", "c",
+	"è", "e", "é", "e", "ê", "e", "ë", "e", "ì", "i", "í", "i", "î", "i", "ï", "i", "ñ", "n",
+	"ò", "o", "ó", "o", "ô", "o", "õ", "o", "ö", "o", "ø", "o", "ù", "u", "ú", "u", "û", "u", "ü", "u",
+	"ý", "y", "ÿ", "y",
+)
+
+// foldCountryName folds a country name for lookups, ignoring case, diacritics, punctuation and spacing,
+// so that "CÔTE D'IVOIRE" and "Cote d Ivoire" are the same name.
+func foldCountryName(name string) string {
+	name = diacritics.Replace(strings.ToLower(name))
+	var b strings.Builder
+	space := false
+	for _, r := range name {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			space = b.Len() > 0
+			continue
+		}
+		if space {
+			b.WriteByte(' ')
+			space = false
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// Countries returns the countries of the reference table, ordered by alpha-2 code.
+func Countries() []Country {
+	return append([]Country(nil), countries.all...)
+}
+
+// LookupCountry returns the country with the given alpha-2 code, case-insensitively.
+func LookupCountry(alpha2 string) (Country, bool) {
+	country, ok := countries.byAlpha2[strings.ToUpper(alpha2)]
+	return country, ok
+}
+
+// ResolveCountry returns the country going by the given name, which may be its short name,
+// one of its aliases or its alpha-2 or alpha-3 code, ignoring case, diacritics and punctuation.
+func ResolveCountry(name string) (Country, bool) {
+	country, ok := countries.byName[foldCountryName(name)]
+	return country, ok
+}
+
+// DeriveCountryCode sets the CountryCode of the port to the alpha-2 code of its Country,
+// or clears it when the country is unknown.
+func (p *Port) DeriveCountryCode() {
+	country, _ := ResolveCountry(p.Country)
+	p.CountryCode = country.Alpha2
+}
+
+// validatePortCountry checks that the country of the port is known, and is the country of its UNLOC.
+func validatePortCountry(sl validator.StructLevel) {
+	p := sl.Current().Interface().(Port)
+	if p.Country == "" {
+		// Missing countries fail the "required" rule
+		return
+	}
+
+	country, ok := ResolveCountry(p.Country)
+	switch {
+	case !ok:
+		sl.ReportError(p.Country, "Country", "Country", RuleCountry, "")
+	case p.UNLOC.Valid() && country.Alpha2 != p.UNLOC.Country():
+		sl.ReportError(p.Country, "Country", "Country", RuleCountryMismatch, p.UNLOC.Country())
+	}
+}
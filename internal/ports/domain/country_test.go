@@ -0,0 +1,104 @@
+package domain
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCountries(t *testing.T) {
+	all := Countries()
+	// The 249 countries of ISO 3166-1, the Netherlands Antilles and Kosovo
+	assert.Len(t, all, 251)
+	for i, country := range all {
+		assert.Len(t, country.Alpha2, 2)
+		assert.Len(t, country.Alpha3, 3)
+		assert.Len(t, country.Numeric, 3)
+		assert.NotEmpty(t, country.Name)
+		if i > 0 {
+			assert.Less(t, all[i-1].Alpha2, country.Alpha2)
+		}
+	}
+
+	country, ok := LookupCountry("ae")
+	assert.True(t, ok)
+	assert.Equal(t, Country{Alpha2: "AE", Alpha3: "ARE", Numeric: "784", Name: "United Arab Emirates"}, country)
+	_, ok = LookupCountry("XZ")
+	assert.False(t, ok)
+}
+
+func TestResolveCountry(t *testing.T) {
+	tests := []struct {
+		name     string
+		expected string
+	}{
+		{name: "United Arab Emirates", expected: "AE"},
+		{name: "UNITED ARAB EMIRATES", expected: "AE"},
+		{name: "AE", expected: "AE"},
+		{name: "are", expected: "AE"},
+		{name: "Côte d'Ivoire", expected: "CI"},
+		{name: "COTE D'IVOIRE", expected: "CI"},
+		{name: "Ivory Coast", expected: "CI"},
+		{name: "Korea, Republic of", expected: "KR"},
+		{name: "Korea Republic Of", expected: "KR"},
+		{name: "South Korea", expected: "KR"},
+		{name: "Turkey", expected: "TR"},
+		{name: "Türkiye", expected: "TR"},
+		{name: "United States of America", expected: "US"},
+		{name: "Netherlands Antilles", expected: "AN"},
+		{name: "Kosovo", expected: "XK"},
+		{name: "XKX", expected: "XK"},
+		{name: "Atlantis"},
+		{name: ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			country, ok := ResolveCountry(test.name)
+			assert.Equal(t, test.expected != "", ok)
+			assert.Equal(t, test.expected, country.Alpha2)
+		})
+	}
+}
+
+func TestPort_Validate_Country(t *testing.T) {
+	tests := []struct {
+		name    string
+		unloc   UNLOCODE
+		country string
+		rule    string
+	}{
+		{name: "name", unloc: "AEJEA", country: "United Arab Emirates"},
+		{name: "alias", unloc: "GBLON", country: "UK"},
+		{name: "code", unloc: "ARBUE", country: "AR"},
+		{name: "user-assigned", unloc: "XKPRN", country: "Kosovo"},
+		{name: "unknown", unloc: "AEJEA", country: "Atlantis", rule: RuleCountry},
+		{name: "mismatch", unloc: "ANEUX", country: "Netherlands", rule: RuleCountryMismatch},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			port := Port{UNLOC: test.unloc, Name: "Port", City: "City", Country: test.country}
+			err := port.Validate()
+			if test.rule == "" {
+				assert.NoError(t, err)
+				return
+			}
+
+			var validationErr *ValidationError
+			assert.ErrorAs(t, err, &validationErr)
+			assert.Equal(t, "Country", validationErr.Fields[0].Field)
+			assert.Equal(t, test.rule, validationErr.Fields[0].Rule)
+		})
+	}
+}
+
+func TestPort_DeriveCountryCode(t *testing.T) {
+	port := Port{UNLOC: "AEJEA", Country: "United Arab Emirates", CountryCode: "XX"}
+	port.DeriveCountryCode()
+	assert.Equal(t, "AE", port.CountryCode)
+
+	port.Country = "Atlantis"
+	port.DeriveCountryCode()
+	assert.Empty(t, port.CountryCode)
+}
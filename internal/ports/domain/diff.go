@@ -0,0 +1,32 @@
+package domain
+
+import "reflect"
+
+// FieldChange describes a field differing between two versions of a port.
+type FieldChange struct {
+	// Field is the JSON name of the field.
+	Field string `json:"field"`
+	Old   any    `json:"old"`
+	New   any    `json:"new"`
+}
+
+// CompareFields returns the fields differing between the old and new versions of a port, in field order.
+// Empty and missing lists are considered equal, as they are by Hash.
+func CompareFields(old, new Port) []FieldChange {
+	var changes []FieldChange
+
+	oldValue, newValue := reflect.ValueOf(old), reflect.ValueOf(new)
+	for i := 0; i < oldValue.NumField(); i++ {
+		a, b := oldValue.Field(i), newValue.Field(i)
+		if a.Kind() == reflect.Slice && a.Len() == 0 && b.Len() == 0 {
+			continue
+		}
+		if reflect.DeepEqual(a.Interface(), b.Interface()) {
+			continue
+		}
+
+		name := jsonName(oldValue.Type().Field(i))
+		changes = append(changes, FieldChange{Field: name, Old: a.Interface(), New: b.Interface()})
+	}
+	return changes
+}
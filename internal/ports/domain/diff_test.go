@@ -0,0 +1,44 @@
+package domain
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompareFields(t *testing.T) {
+	old := Port{UNLOC: "AEJEA", Name: "Jebel Ali", City: "Dubai", Country: "United Arab Emirates", Alias: []string{}}
+
+	tests := []struct {
+		name     string
+		new      func(p Port) Port
+		expected []FieldChange
+	}{
+		{
+			name: "identical",
+			new:  func(p Port) Port { return p },
+		},
+		{
+			name: "empty and missing lists",
+			new:  func(p Port) Port { p.Alias = nil; return p },
+		},
+		{
+			name: "changed fields",
+			new: func(p Port) Port {
+				p.Name = "Jebel Ali Port"
+				p.Coordinates = &GeoPoint{Lat: 24.9857145, Lon: 55.0272904}
+				return p
+			},
+			expected: []FieldChange{
+				{Field: "name", Old: "Jebel Ali", New: "Jebel Ali Port"},
+				{Field: "coordinates", Old: (*GeoPoint)(nil), New: &GeoPoint{Lat: 24.9857145, Lon: 55.0272904}},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.expected, CompareFields(old, test.new(old)))
+		})
+	}
+}
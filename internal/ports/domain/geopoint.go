@@ -0,0 +1,105 @@
+package domain
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// GeoPoint is a geographic position in decimal degrees, positive north of the equator and east of
+// the prime meridian. It is encoded in JSON as a [lon, lat] array, as in GeoJSON.
+type GeoPoint struct {
+	Lat float64
+	Lon float64
+	// malformed holds the coordinates as found in the source when they could not be decoded,
+	// see MalformedGeoPoint.
+	malformed string
+}
+
+// MalformedGeoPoint returns a point standing for coordinates that could not be decoded from the given
+// value, which fails validation so that the port holding it is rejected rather than the whole input.
+func MalformedGeoPoint(value string) GeoPoint {
+	return GeoPoint{malformed: value}
+}
+
+// Validation rules of geographic positions, reported on the Lat or Lon field of the point,
+// or on its Coordinates for malformed ones.
+const (
+	// RuleCoordinates is not satisfied by malformed points, see MalformedGeoPoint.
+	RuleCoordinates = "coordinates"
+	// RuleLatitude is not satisfied by latitudes outside of [-90, 90].
+	RuleLatitude = "latitude"
+	// RuleLongitude is not satisfied by longitudes outside of [-180, 180].
+	RuleLongitude = "longitude"
+	// RuleSwapped is not satisfied by points whose latitude is out of range while their longitude
+	// would be a valid latitude, as when a source writes [lat, lon] arrays.
+	RuleSwapped = "swapped"
+	// RuleNullIsland is not satisfied by the point at (0,0), which sources write for unknown positions.
+	RuleNullIsland = "null_island"
+)
+
+// validateGeoPoint checks that the point is within range and looks like an actual position.
+// Points are only ever reported for a single rule, the first they fail among coordinates, swapped,
+// latitude, longitude and null island.
+func validateGeoPoint(sl validator.StructLevel) {
+	p := sl.Current().Interface().(GeoPoint)
+	switch {
+	case p.malformed != "":
+		sl.ReportError(p.malformed, "Coordinates", "Coordinates", RuleCoordinates, "")
+	case p.LooksSwapped():
+		sl.ReportError(p.Lat, "Lat", "Lat", RuleSwapped, "")
+	case !inRange(p.Lat, 90):
+		sl.ReportError(p.Lat, "Lat", "Lat", RuleLatitude, "")
+	case !inRange(p.Lon, 180):
+		sl.ReportError(p.Lon, "Lon", "Lon", RuleLongitude, "")
+	case p.Lat == 0 && p.Lon == 0:
+		sl.ReportError(p.Lat, "Lat", "Lat", RuleNullIsland, "")
+	}
+}
+
+// inRange reports whether the angle is within [-limit, limit], which NaN is not.
+func inRange(angle, limit float64) bool {
+	return angle >= -limit && angle <= limit
+}
+
+// LooksSwapped reports whether the latitude and longitude of the point look swapped: the latitude
+// is out of range while the longitude would be a valid latitude and the latitude a valid longitude.
+func (p GeoPoint) LooksSwapped() bool {
+	return !inRange(p.Lat, 90) && inRange(p.Lat, 180) && inRange(p.Lon, 90)
+}
+
+func (p GeoPoint) String() string {
+	if p.malformed != "" {
+		return p.malformed
+	}
+	return fmt.Sprintf("%g,%g", p.Lat, p.Lon)
+}
+
+// MarshalJSON encodes the point as a [lon, lat] array, or a malformed point as the value it was decoded from.
+func (p GeoPoint) MarshalJSON() ([]byte, error) {
+	if p.malformed != "" {
+		if json.Valid([]byte(p.malformed)) {
+			return []byte(p.malformed), nil
+		}
+		return json.Marshal(p.malformed)
+	}
+	return json.Marshal([2]float64{p.Lon, p.Lat})
+}
+
+// UnmarshalJSON decodes a [lon, lat] array. Any other value decodes into a malformed point, leaving it to
+// validation to reject it along with the points out of range.
+func (p *GeoPoint) UnmarshalJSON(data []byte) error {
+	var values []float64
+	if err := json.Unmarshal(data, &values); err != nil || len(values) != 2 {
+		var compact bytes.Buffer
+		if err := json.Compact(&compact, data); err != nil {
+			return err
+		}
+		*p = MalformedGeoPoint(compact.String())
+		return nil
+	}
+	*p = GeoPoint{Lat: values[1], Lon: values[0]}
+	return nil
+}
@@ -0,0 +1,102 @@
+package domain
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGeoPoint_JSON(t *testing.T) {
+	point := GeoPoint{Lat: 24.9857145, Lon: 55.0272904}
+	data, err := json.Marshal(point)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `[55.0272904, 24.9857145]`, string(data))
+
+	var decoded GeoPoint
+	assert.NoError(t, json.Unmarshal(data, &decoded))
+	assert.Equal(t, point, decoded)
+
+	// Other values decode into malformed points, encoded back as they were found
+	for _, value := range []string{`[55.0272904]`, `[55.0272904,24.9857145,0]`, `{"lat":24.9857145,"lon":55.0272904}`, `"2523N 05518E"`} {
+		assert.NoError(t, json.Unmarshal([]byte(value), &decoded), value)
+		assert.Equal(t, MalformedGeoPoint(value), decoded)
+		data, err := json.Marshal(decoded)
+		assert.NoError(t, err)
+		assert.Equal(t, value, string(data))
+	}
+
+	data, err = json.Marshal(MalformedGeoPoint("2523X 05518E"))
+	assert.NoError(t, err)
+	assert.Equal(t, `"2523X 05518E"`, string(data))
+}
+
+func TestPort_UnmarshalJSON_Coordinates(t *testing.T) {
+	tests := []struct {
+		name        string
+		coordinates string
+		expected    *GeoPoint
+	}{
+		{name: "point", coordinates: `, "coordinates": [55.0272904, 24.9857145]`, expected: &GeoPoint{Lat: 24.9857145, Lon: 55.0272904}},
+		{name: "missing", coordinates: ``},
+		{name: "null", coordinates: `, "coordinates": null`},
+		{name: "empty array", coordinates: `, "coordinates": []`},
+		{name: "single value", coordinates: `, "coordinates": [55.0272904]`, expected: &GeoPoint{malformed: `[55.0272904]`}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var port Port
+			err := json.Unmarshal([]byte(`{"unloc": "AEJEA", "name": "Jebel Ali"`+test.coordinates+`}`), &port)
+			assert.NoError(t, err)
+			assert.Equal(t, UNLOCODE("AEJEA"), port.UNLOC)
+			assert.Equal(t, "Jebel Ali", port.Name)
+			assert.Equal(t, test.expected, port.Coordinates)
+		})
+	}
+}
+
+func TestPort_MarshalJSON_Coordinates(t *testing.T) {
+	port := Port{UNLOC: "AEJEA", Name: "Jebel Ali", Province: "coordinates"}
+	data, err := json.Marshal(port)
+	assert.NoError(t, err)
+	assert.Contains(t, string(data), `"coordinates":[],"province":"coordinates"`)
+
+	port.Coordinates = &GeoPoint{Lat: 24.9857145, Lon: 55.0272904}
+	data, err = json.Marshal(port)
+	assert.NoError(t, err)
+	assert.Contains(t, string(data), `"coordinates":[55.0272904,24.9857145]`)
+}
+
+func TestPort_Validate_Coordinates(t *testing.T) {
+	tests := []struct {
+		name        string
+		coordinates *GeoPoint
+		field       string
+		rule        string
+	}{
+		{name: "missing"},
+		{name: "valid", coordinates: &GeoPoint{Lat: 24.9857145, Lon: 55.0272904}},
+		{name: "antimeridian", coordinates: &GeoPoint{Lat: -90, Lon: 180}},
+		{name: "swapped", coordinates: &GeoPoint{Lat: 151.2093, Lon: -33.8688}, field: "Lat", rule: RuleSwapped},
+		{name: "latitude out of range", coordinates: &GeoPoint{Lat: 191.2, Lon: 55.0272904}, field: "Lat", rule: RuleLatitude},
+		{name: "longitude out of range", coordinates: &GeoPoint{Lat: 24.9857145, Lon: -250}, field: "Lon", rule: RuleLongitude},
+		{name: "null island", coordinates: &GeoPoint{}, field: "Lat", rule: RuleNullIsland},
+		{name: "malformed", coordinates: &GeoPoint{malformed: "[55.0272904]"}, field: "Coordinates", rule: RuleCoordinates},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			port := Port{UNLOC: "AEJEA", Name: "Jebel Ali", City: "Dubai", Country: "United Arab Emirates", Coordinates: test.coordinates}
+			err := port.Validate()
+			if test.rule == "" {
+				assert.NoError(t, err)
+				return
+			}
+
+			var validationErr *ValidationError
+			assert.ErrorAs(t, err, &validationErr)
+			assert.Equal(t, []FieldError{{Field: test.field, Rule: test.rule, Message: validationErr.Fields[0].Message}}, validationErr.Fields)
+		})
+	}
+}
@@ -0,0 +1,155 @@
+package domain
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// MergeStrategy tells how a field of a stored port is merged with the same field of an imported port.
+type MergeStrategy string
+
+const (
+	// Replace replaces the stored field with the imported one, even when empty. It is the default.
+	Replace MergeStrategy = "replace"
+	// KeepExisting keeps the stored field unless it is empty, in which case the imported one is taken.
+	KeepExisting MergeStrategy = "keep-existing"
+	// Union appends the imported items missing from the stored list. It only applies to the alias, regions
+	// and unlocs lists, the other fields being replaced when it is the default strategy.
+	Union MergeStrategy = "union"
+)
+
+// unionFields are the fields Union applies to, by JSON name.
+var unionFields = map[string]bool{"alias": true, "regions": true, "unlocs": true}
+
+// MergePolicy sets how stored ports are merged with imported ones, field by field.
+// The zero value replaces stored ports as a whole.
+type MergePolicy struct {
+	// Default is the strategy of the fields missing from Fields, Replace when empty.
+	Default MergeStrategy
+	// Fields sets the strategy of single fields, by JSON name.
+	Fields map[string]MergeStrategy
+}
+
+// ParseMergePolicy parses a comma separated list of strategies, each of them either applying to
+// a single field as in "alias=union", or being the default one as in "keep-existing".
+func ParseMergePolicy(s string) (MergePolicy, error) {
+	var policy MergePolicy
+	for _, entry := range strings.Split(s, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		field, strategy, ok := strings.Cut(entry, "=")
+		if !ok {
+			policy.Default = MergeStrategy(entry)
+			continue
+		}
+		if policy.Fields == nil {
+			policy.Fields = make(map[string]MergeStrategy)
+		}
+		policy.Fields[strings.TrimSpace(field)] = MergeStrategy(strings.TrimSpace(strategy))
+	}
+	return policy, policy.Validate()
+}
+
+// Validate checks that the policy only holds known strategies and fields, and that Union
+// is only set on the fields it applies to.
+func (p MergePolicy) Validate() error {
+	switch p.Default {
+	case "", Replace, KeepExisting, Union:
+	default:
+		return fmt.Errorf("unknown merge strategy %q", p.Default)
+	}
+
+	fields := portFields()
+	for field, strategy := range p.Fields {
+		if _, exists := fields[field]; !exists {
+			return fmt.Errorf("unknown port field %q", field)
+		}
+		switch {
+		case strategy == Union && !unionFields[field]:
+			return fmt.Errorf("merge strategy %q does not apply to field %q", strategy, field)
+		case strategy != Replace && strategy != KeepExisting && strategy != Union:
+			return fmt.Errorf("unknown merge strategy %q for field %q", strategy, field)
+		}
+	}
+	return nil
+}
+
+// ReplacesAll reports whether the policy replaces stored ports as a whole, so that they need not be read.
+func (p MergePolicy) ReplacesAll() bool {
+	if p.Default != "" && p.Default != Replace {
+		return false
+	}
+	for _, strategy := range p.Fields {
+		if strategy != Replace {
+			return false
+		}
+	}
+	return true
+}
+
+// Merge returns the imported port merged into the stored one with the strategy of each field.
+// The UNLOC is always the one of the imported port.
+func (p MergePolicy) Merge(stored, imported Port) Port {
+	merged := imported
+	mergedValue, storedValue := reflect.ValueOf(&merged).Elem(), reflect.ValueOf(stored)
+	for i := 0; i < mergedValue.NumField(); i++ {
+		name := jsonName(mergedValue.Type().Field(i))
+		if name == "unloc" {
+			continue
+		}
+
+		strategy, ok := p.Fields[name]
+		if !ok {
+			strategy = p.Default
+		}
+		field, old := mergedValue.Field(i), storedValue.Field(i)
+		switch {
+		case strategy == KeepExisting && !old.IsZero() && !(old.Kind() == reflect.Slice && old.Len() == 0):
+			field.Set(old)
+		case strategy == Union && unionFields[name]:
+			field.Set(union(old, field))
+		}
+	}
+	return merged
+}
+
+// union returns the items of the slice a followed by the ones of the slice b missing from a, each of them once.
+func union(a, b reflect.Value) reflect.Value {
+	if b.Len() == 0 {
+		return a
+	}
+
+	seen := make(map[any]bool, a.Len()+b.Len())
+	result := reflect.MakeSlice(a.Type(), 0, a.Len()+b.Len())
+	for _, items := range []reflect.Value{a, b} {
+		for i := 0; i < items.Len(); i++ {
+			if item := items.Index(i); !seen[item.Interface()] {
+				seen[item.Interface()] = true
+				result = reflect.Append(result, item)
+			}
+		}
+	}
+	return result
+}
+
+// portFields returns the JSON names of the fields of a port.
+func portFields() map[string]struct{} {
+	t := reflect.TypeOf(Port{})
+	fields := make(map[string]struct{}, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		fields[jsonName(t.Field(i))] = struct{}{}
+	}
+	return fields
+}
+
+// jsonName returns the JSON name of a struct field.
+func jsonName(field reflect.StructField) string {
+	name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+	if name == "" {
+		return field.Name
+	}
+	return name
+}
@@ -0,0 +1,97 @@
+package domain
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMergePolicy_Merge(t *testing.T) {
+	stored := Port{
+		UNLOC: "AEJEA", Name: "Jebel Ali", City: "Dubai", Country: "United Arab Emirates",
+		Alias: []string{"Mina Jebel Ali"}, Regions: []string{"Dubai"}, Province: "Dubai",
+	}
+	imported := Port{
+		UNLOC: "AEJEA", Name: "Jebel Ali Port", City: "Dubai", Country: "United Arab Emirates",
+		Alias: []string{"Jebel Ali Harbour", "Mina Jebel Ali"}, Timezone: "Asia/Dubai",
+	}
+
+	tests := []struct {
+		name     string
+		policy   MergePolicy
+		expected func(p Port) Port
+	}{
+		{
+			name:     "replace",
+			policy:   MergePolicy{},
+			expected: func(p Port) Port { return imported },
+		},
+		{
+			name:   "keep existing",
+			policy: MergePolicy{Default: KeepExisting},
+			expected: func(p Port) Port {
+				p.Timezone = "Asia/Dubai"
+				return p
+			},
+		},
+		{
+			name:   "union",
+			policy: MergePolicy{Default: Union},
+			expected: func(p Port) Port {
+				p = imported
+				p.Alias = []string{"Mina Jebel Ali", "Jebel Ali Harbour"}
+				p.Regions = []string{"Dubai"}
+				return p
+			},
+		},
+		{
+			name:   "per field",
+			policy: MergePolicy{Default: KeepExisting, Fields: map[string]MergeStrategy{"name": Replace, "alias": Union}},
+			expected: func(p Port) Port {
+				p.Name = "Jebel Ali Port"
+				p.Alias = []string{"Mina Jebel Ali", "Jebel Ali Harbour"}
+				p.Timezone = "Asia/Dubai"
+				return p
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.NoError(t, test.policy.Validate())
+			assert.Equal(t, test.expected(stored), test.policy.Merge(stored, imported))
+		})
+	}
+}
+
+func TestParseMergePolicy(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected MergePolicy
+		wantErr  bool
+	}{
+		{input: "", expected: MergePolicy{}},
+		{input: "keep-existing", expected: MergePolicy{Default: KeepExisting}},
+		{
+			input:    "keep-existing, alias=union,name=replace",
+			expected: MergePolicy{Default: KeepExisting, Fields: map[string]MergeStrategy{"alias": Union, "name": Replace}},
+		},
+		{input: "random", wantErr: true},
+		{input: "altitude=replace", wantErr: true},
+		{input: "name=union", wantErr: true},
+		{input: "name=random", wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			policy, err := ParseMergePolicy(test.input)
+			if test.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, test.expected, policy)
+			assert.Equal(t, test.input == "", policy.ReplacesAll())
+		})
+	}
+}
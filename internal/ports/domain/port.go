@@ -1,38 +1,194 @@
 package domain
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"strings"
 
 	"github.com/go-playground/validator/v10"
 )
 
+// validate is shared by all validations, as the validator caches struct metadata
+// and is safe for concurrent use.
+var validate = newValidator()
+
+// newValidator returns a validator knowing the "unlocode" rule, satisfied by well-formed UNLOCODEs,
+// and the "timezone" rule, satisfied by the names of the IANA database, in place of the validator's own
+// which depends on the host. It checks geographic positions and the country of ports as well,
+// see validateGeoPoint and validatePortCountry.
+func newValidator() *validator.Validate {
+	v := validator.New()
+	// Registering a rule only fails without a name
+	_ = v.RegisterValidation("unlocode", func(fl validator.FieldLevel) bool {
+		return UNLOCODE(fl.Field().String()).Valid()
+	})
+	_ = v.RegisterValidation("timezone", func(fl validator.FieldLevel) bool {
+		_, err := loadLocation(fl.Field().String())
+		return err == nil
+	})
+	v.RegisterStructValidation(validateGeoPoint, GeoPoint{})
+	v.RegisterStructValidation(validatePortCountry, Port{})
+	return v
+}
+
 // Port represents a port entity.
 type Port struct {
-	UNLOC       string    `json:"unloc" validate:"required,len=5"`
-	Name        string    `json:"name" validate:"required"`
-	City        string    `json:"city" validate:"required"`
-	Country     string    `json:"country" validate:"required"`
-	Alias       []string  `json:"alias"`
-	Regions     []string  `json:"regions"`
-	Coordinates []float64 `json:"coordinates"`
-	Province    string    `json:"province"`
-	Timezone    string    `json:"timezone"`
-	UNLOCs      []string  `json:"unlocs" validate:"dive,len=5"`
-	Code        string    `json:"code"`
+	UNLOC   UNLOCODE `json:"unloc" validate:"required,unlocode"`
+	Name    string   `json:"name" validate:"required"`
+	City    string   `json:"city" validate:"required"`
+	Country string   `json:"country" validate:"required"`
+	// CountryCode is the ISO 3166-1 alpha-2 code of Country, derived on import, see DeriveCountryCode.
+	CountryCode string     `json:"country_code,omitempty"`
+	Alias       []string   `json:"alias"`
+	Regions     []string   `json:"regions"`
+	Coordinates *GeoPoint  `json:"coordinates"`
+	Province    string     `json:"province"`
+	Timezone    string     `json:"timezone" validate:"omitempty,timezone"`
+	UNLOCs      []UNLOCODE `json:"unlocs" validate:"dive,unlocode"`
+	Code        string     `json:"code"`
+}
+
+// UnmarshalJSON decodes the port, taking coordinates given as an empty array for missing ones,
+// as they were before GeoPoint.
+func (p *Port) UnmarshalJSON(data []byte) error {
+	type port Port
+	decoded := struct {
+		*port
+		Coordinates json.RawMessage `json:"coordinates"`
+	}{port: (*port)(p)}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return err
+	}
+
+	switch {
+	case decoded.Coordinates == nil:
+	case string(decoded.Coordinates) == "null" || isEmptyArray(decoded.Coordinates):
+		p.Coordinates = nil
+	default:
+		var coordinates GeoPoint
+		if err := json.Unmarshal(decoded.Coordinates, &coordinates); err != nil {
+			return fmt.Errorf("invalid coordinates: %w", err)
+		}
+		p.Coordinates = &coordinates
+	}
+	return nil
+}
+
+// MarshalJSON encodes the port, writing missing coordinates as an empty array as they were before GeoPoint.
+func (p Port) MarshalJSON() ([]byte, error) {
+	type port Port
+	data, err := json.Marshal(port(p))
+	if err != nil || p.Coordinates != nil {
+		return data, err
+	}
+	// Quotes are escaped within strings, so only the coordinates key can be followed by a bare colon
+	return bytes.Replace(data, []byte(`"coordinates":null`), []byte(`"coordinates":[]`), 1), nil
+}
+
+// isEmptyArray reports whether the JSON value is an empty array.
+func isEmptyArray(data json.RawMessage) bool {
+	var values []json.RawMessage
+	return json.Unmarshal(data, &values) == nil && len(values) == 0
+}
+
+// FieldError describes a field of a port failing validation.
+type FieldError struct {
+	// Field is the name of the field.
+	Field string
+	// Rule is the validation rule the field does not satisfy, e.g. "required".
+	Rule string
+	// Message is the message of the validator.
+	Message string
+}
+
+// ValidationError is returned when a port fails validation.
+type ValidationError struct {
+	Port   Port
+	Fields []FieldError
+}
+
+func (e *ValidationError) Error() string {
+	details := make([]string, len(e.Fields))
+	for i, field := range e.Fields {
+		details[i] = field.Message
+	}
+	return fmt.Sprintf("validation error in struct: %+v, with details: '%v'", e.Port, strings.Join(details, ", "))
 }
 
 // Validate performs validation on the Port struct.
-// If validation fails, it returns an error with the details of the validation errors.
+// If validation fails, it returns a *ValidationError with the details of the validation errors.
 func (p *Port) Validate() error {
-	validate := validator.New()
 	err := validate.Struct(p)
 	if err != nil {
-		var validationErrors []string
+		validationErr := &ValidationError{Port: *p}
 		for _, err := range err.(validator.ValidationErrors) {
-			validationErrors = append(validationErrors, err.Error())
+			validationErr.Fields = append(validationErr.Fields, FieldError{
+				Field:   err.Field(),
+				Rule:    err.Tag(),
+				Message: err.Error(),
+			})
 		}
-		return fmt.Errorf("validation error in struct: %+v, with details: '%v'", *p, strings.Join(validationErrors, ", "))
+		return validationErr
 	}
 	return nil
 }
+
+// Hash returns a canonical hash of the port, equal for ports holding the same data.
+// Empty and missing lists are considered equal.
+func (p Port) Hash() string {
+	p.Alias = nilIfEmpty(p.Alias)
+	p.Regions = nilIfEmpty(p.Regions)
+	p.UNLOCs = nilIfEmpty(p.UNLOCs)
+
+	// Ports always marshal, and struct fields are marshalled in a fixed order. Missing coordinates are
+	// hashed as null, as they were before being marshalled as an empty array, to keep the stored hashes.
+	type port Port
+	data, _ := json.Marshal(port(p))
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func nilIfEmpty[T any](s []T) []T {
+	if len(s) == 0 {
+		return nil
+	}
+	return s
+}
+
+// Merge returns the port updated with the fields set in other, keeping its own fields where other
+// leaves them empty.
+func (p Port) Merge(other Port) Port {
+	mergeString(&p.UNLOC, other.UNLOC)
+	mergeString(&p.Name, other.Name)
+	mergeString(&p.City, other.City)
+	mergeString(&p.Country, other.Country)
+	mergeSlice(&p.Alias, other.Alias)
+	mergeSlice(&p.Regions, other.Regions)
+	mergePointer(&p.Coordinates, other.Coordinates)
+	mergeString(&p.Province, other.Province)
+	mergeString(&p.Timezone, other.Timezone)
+	mergeSlice(&p.UNLOCs, other.UNLOCs)
+	mergeString(&p.Code, other.Code)
+	return p
+}
+
+func mergeString[T ~string](field *T, value T) {
+	if value != "" {
+		*field = value
+	}
+}
+
+func mergeSlice[T any](field *[]T, value []T) {
+	if len(value) > 0 {
+		*field = value
+	}
+}
+
+func mergePointer[T any](field **T, value *T) {
+	if value != nil {
+		*field = value
+	}
+}
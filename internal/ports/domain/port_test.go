@@ -44,6 +44,16 @@ func TestPortValidation(t *testing.T) {
 			port:             Port{Name: "Jebel Ali", City: "Dubai", Country: "United Arab Emirates", UNLOC: "AEJE"},
 			expectedErrorMsg: "Field validation for 'UNLOC'",
 		},
+		{
+			name:             "Numeric UNLOC",
+			port:             Port{Name: "Jebel Ali", City: "Dubai", Country: "United Arab Emirates", UNLOC: "12345"},
+			expectedErrorMsg: "Field validation for 'UNLOC'",
+		},
+		{
+			name:             "Invalid UNLOCs",
+			port:             Port{Name: "Jebel Ali", City: "Dubai", Country: "United Arab Emirates", UNLOC: "AEJEA", UNLOCs: []UNLOCODE{"AEJEA", "AEJE1"}},
+			expectedErrorMsg: "Field validation for 'UNLOCs[1]'",
+		},
 	}
 
 	for _, test := range tests {
@@ -53,7 +63,42 @@ func TestPortValidation(t *testing.T) {
 				assert.NoError(t, err)
 			} else {
 				assert.ErrorContains(t, err, test.expectedErrorMsg)
+
+				var validationErr *ValidationError
+				assert.ErrorAs(t, err, &validationErr)
+				assert.Len(t, validationErr.Fields, 1)
 			}
 		})
 	}
 }
+
+func TestPortHash(t *testing.T) {
+	port := Port{UNLOC: "AEJEA", Name: "Jebel Ali", City: "Dubai", Country: "United Arab Emirates", Alias: []string{}}
+
+	withoutAlias := port
+	withoutAlias.Alias = nil
+	assert.Equal(t, port.Hash(), withoutAlias.Hash())
+
+	renamed := port
+	renamed.Name = "Jebel Ali Port"
+	assert.NotEqual(t, port.Hash(), renamed.Hash())
+
+	moved := port
+	moved.Coordinates = &GeoPoint{Lat: 24.9857145, Lon: 55.0272904}
+	assert.NotEqual(t, port.Hash(), moved.Hash())
+	assert.Len(t, port.Hash(), 64)
+}
+
+func TestPortMerge(t *testing.T) {
+	port := Port{UNLOC: "AEJEA", Name: "Jebel Ali", City: "Dubai", Country: "United Arab Emirates", Alias: []string{"JEA"}}
+
+	merged := port.Merge(Port{UNLOC: "AEJEA", Name: "Jebel Ali Port", Timezone: "Asia/Dubai", Alias: []string{}})
+	assert.Equal(t, Port{
+		UNLOC:    "AEJEA",
+		Name:     "Jebel Ali Port",
+		City:     "Dubai",
+		Country:  "United Arab Emirates",
+		Alias:    []string{"JEA"},
+		Timezone: "Asia/Dubai",
+	}, merged)
+}
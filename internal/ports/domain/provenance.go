@@ -0,0 +1,78 @@
+package domain
+
+import (
+	"encoding/json"
+	"hash/fnv"
+	"reflect"
+	"strconv"
+	"time"
+)
+
+// Origin identifies the import which last changed a port, or a field of a port.
+type Origin struct {
+	// Source is the name of the imported source, e.g. its file path.
+	Source string `json:"source"`
+	// Checksum identifies the content of the source, see Checkpoint Fingerprint. It is empty when unknown.
+	Checksum string `json:"checksum,omitempty"`
+	// RunID identifies the import run.
+	RunID string `json:"run_id"`
+	// Offset is the byte offset of the record in the source.
+	Offset int64 `json:"offset"`
+	// ChangedAt is the time of the change.
+	ChangedAt time.Time `json:"changed_at"`
+}
+
+// FieldOrigin is the origin of the value of a field of a port.
+type FieldOrigin struct {
+	Origin
+	// Digest identifies the value the field was given, to tell whether later imports change it.
+	Digest string `json:"digest"`
+}
+
+// Provenance records where a stored port comes from. It is stored apart from the port.
+type Provenance struct {
+	// Origin is the origin of the last change of the port.
+	Origin
+	// Fields holds the origin of the value of each field set on the port, by JSON name.
+	// Fields kept by a MergePolicy keep their origin.
+	Fields map[string]FieldOrigin `json:"fields,omitempty"`
+}
+
+// Update returns the provenance of the port once written by the given origin, which becomes the origin
+// of the fields whose value changed. It returns false along with the provenance as is when no field changed.
+func (p Provenance) Update(port Port, origin Origin) (Provenance, bool) {
+	fields := make(map[string]FieldOrigin, len(p.Fields))
+	changed := false
+
+	value := reflect.ValueOf(port)
+	for i := 0; i < value.NumField(); i++ {
+		name, field := jsonName(value.Type().Field(i)), value.Field(i)
+		previous, known := p.Fields[name]
+		if field.IsZero() || field.Kind() == reflect.Slice && field.Len() == 0 {
+			changed = changed || known
+			continue
+		}
+
+		digest := fieldDigest(field.Interface())
+		if known && previous.Digest == digest {
+			fields[name] = previous
+			continue
+		}
+		fields[name] = FieldOrigin{Origin: origin, Digest: digest}
+		changed = true
+	}
+
+	if !changed {
+		return p, false
+	}
+	return Provenance{Origin: origin, Fields: fields}, true
+}
+
+// fieldDigest returns a short digest of the value of a field.
+func fieldDigest(value any) string {
+	// Fields always marshal
+	data, _ := json.Marshal(value)
+	h := fnv.New64a()
+	_, _ = h.Write(data)
+	return strconv.FormatUint(h.Sum64(), 16)
+}
@@ -0,0 +1,46 @@
+package domain
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProvenance_Update(t *testing.T) {
+	port := Port{UNLOC: "AEJEA", Name: "Jebel Ali", City: "Dubai", Country: "United Arab Emirates", Alias: []string{}}
+	first := Origin{Source: "ports.json", RunID: "run-1", Offset: 10, ChangedAt: time.Unix(1, 0)}
+	second := Origin{Source: "fixes.json", RunID: "run-2", Offset: 20, ChangedAt: time.Unix(2, 0)}
+
+	provenance, changed := Provenance{}.Update(port, first)
+	assert.True(t, changed)
+	assert.Equal(t, first, provenance.Origin)
+	assert.Equal(t, []string{"city", "country", "name", "unloc"}, fieldNames(provenance))
+
+	// Writing the same port again changes nothing
+	unchanged, changed := provenance.Update(port, second)
+	assert.False(t, changed)
+	assert.Equal(t, provenance, unchanged)
+
+	port.Name = "Jebel Ali Port"
+	port.City = ""
+	port.Alias = []string{"Mina Jebel Ali"}
+	provenance, changed = provenance.Update(port, second)
+	assert.True(t, changed)
+	assert.Equal(t, second, provenance.Origin)
+	assert.Equal(t, []string{"alias", "country", "name", "unloc"}, fieldNames(provenance))
+	assert.Equal(t, second, provenance.Fields["name"].Origin)
+	assert.Equal(t, second, provenance.Fields["alias"].Origin)
+	assert.Equal(t, first, provenance.Fields["country"].Origin)
+}
+
+// fieldNames returns the names of the fields of the provenance, in alphabetical order.
+func fieldNames(p Provenance) []string {
+	var names []string
+	for _, name := range []string{"alias", "city", "code", "coordinates", "country", "name", "province", "regions", "timezone", "unloc", "unlocs"} {
+		if _, ok := p.Fields[name]; ok {
+			names = append(names, name)
+		}
+	}
+	return names
+}
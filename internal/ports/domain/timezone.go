@@ -0,0 +1,166 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	// Timezones are checked against the IANA database embedded in the binary, whatever the host holds
+	_ "time/tzdata"
+)
+
+// ErrNoTimezone is returned when computing the local time at a port without a timezone.
+var ErrNoTimezone = errors.New("port has no timezone")
+
+// locations caches the timezones by IANA name, as loading them parses the database.
+var locations sync.Map
+
+// loadLocation returns the timezone of the given IANA name, e.g. "Asia/Dubai". Unlike time.LoadLocation,
+// it rejects the empty name and "Local", which do not name a timezone but UTC and the host's own.
+func loadLocation(name string) (*time.Location, error) {
+	if cached, ok := locations.Load(name); ok {
+		return cached.(*time.Location), nil
+	}
+	if name == "" || name == "Local" {
+		return nil, fmt.Errorf("unknown timezone %q", name)
+	}
+	location, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("unknown timezone %q: %w", name, err)
+	}
+	locations.Store(name, location)
+	return location, nil
+}
+
+// Location returns the timezone of the port, or ErrNoTimezone when it has none.
+func (p Port) Location() (*time.Location, error) {
+	if p.Timezone == "" {
+		return nil, fmt.Errorf("%w: %s", ErrNoTimezone, p.UNLOC)
+	}
+	return loadLocation(p.Timezone)
+}
+
+// LocalTime returns the instant t as seen at the port.
+func (p Port) LocalTime(t time.Time) (time.Time, error) {
+	location, err := p.Location()
+	if err != nil {
+		return time.Time{}, err
+	}
+	return t.In(location), nil
+}
+
+// UTCOffset returns the offset from UTC of the local time at the port at the instant t,
+// positive east of UTC.
+func (p Port) UTCOffset(t time.Time) (time.Duration, error) {
+	local, err := p.LocalTime(t)
+	if err != nil {
+		return 0, err
+	}
+	_, offset := local.Zone()
+	return time.Duration(offset) * time.Second, nil
+}
+
+// Transition is a change of the UTC offset at a port, typically the start or the end of daylight saving time.
+type Transition struct {
+	// At is the instant of the change, in the local time following it.
+	At time.Time `json:"at"`
+	// Zone is the abbreviation of the zone following the change, e.g. "CEST".
+	Zone string `json:"zone"`
+	// UTCOffsetSeconds is the offset from UTC following the change, in seconds east of UTC.
+	UTCOffsetSeconds int `json:"utc_offset_seconds"`
+	// DST reports whether daylight saving time is in effect following the change.
+	DST bool `json:"dst"`
+}
+
+// Transitions returns the changes of UTC offset or of daylight saving time at the port after
+// the instant from and up to until, in chronological order. Zones changing only their abbreviation are skipped.
+func (p Port) Transitions(from, until time.Time) ([]Transition, error) {
+	local, err := p.LocalTime(from)
+	if err != nil {
+		return nil, err
+	}
+
+	var transitions []Transition
+	for {
+		_, end := local.ZoneBounds()
+		// The zone lasts forever when it has no end
+		if end.IsZero() || end.After(until) {
+			return transitions, nil
+		}
+		_, offset := local.Zone()
+		zone, nextOffset := end.Zone()
+		if nextOffset != offset || end.IsDST() != local.IsDST() {
+			transitions = append(transitions, Transition{At: end, Zone: zone, UTCOffsetSeconds: nextOffset, DST: end.IsDST()})
+		}
+		local = end
+	}
+}
+
+// BusinessHours are the hours a port is open for business, in the local time of the port.
+type BusinessHours struct {
+	// Days are the days the port opens on, Monday to Friday when empty.
+	Days []time.Weekday
+	// Open and Close are the opening and closing times, as durations since midnight.
+	// Hours closing no later than they open span midnight, and close the day after opening.
+	Open  time.Duration
+	Close time.Duration
+}
+
+// DefaultBusinessHours are from 9:00 to 17:00, Monday to Friday.
+var DefaultBusinessHours = BusinessHours{Open: 9 * time.Hour, Close: 17 * time.Hour}
+
+// ParseBusinessHours parses opening and closing times such as "09:00-17:00", open Monday to Friday.
+func ParseBusinessHours(s string) (BusinessHours, error) {
+	open, close, ok := strings.Cut(s, "-")
+	if !ok {
+		return BusinessHours{}, fmt.Errorf("invalid business hours %q, expected opening and closing times such as 09:00-17:00", s)
+	}
+	var hours BusinessHours
+	for _, bound := range []struct {
+		value string
+		to    *time.Duration
+	}{{open, &hours.Open}, {close, &hours.Close}} {
+		t, err := time.Parse("15:04", strings.TrimSpace(bound.value))
+		if err != nil {
+			return BusinessHours{}, fmt.Errorf("invalid business hours %q: %w", s, err)
+		}
+		*bound.to = time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute
+	}
+	return hours, nil
+}
+
+// Contains reports whether the local time falls within the business hours. It goes by the wall clock,
+// so that hours are kept across daylight saving time changes.
+func (h BusinessHours) Contains(local time.Time) bool {
+	sinceMidnight := time.Duration(local.Hour())*time.Hour + time.Duration(local.Minute())*time.Minute +
+		time.Duration(local.Second())*time.Second
+	if h.Close > h.Open {
+		return h.opensOn(local.Weekday()) && sinceMidnight >= h.Open && sinceMidnight < h.Close
+	}
+	// Past midnight, the hours are the ones opened the day before
+	return h.opensOn(local.Weekday()) && sinceMidnight >= h.Open ||
+		h.opensOn((local.Weekday()+6)%7) && sinceMidnight < h.Close
+}
+
+func (h BusinessHours) opensOn(day time.Weekday) bool {
+	if len(h.Days) == 0 {
+		return day >= time.Monday && day <= time.Friday
+	}
+	for _, d := range h.Days {
+		if d == day {
+			return true
+		}
+	}
+	return false
+}
+
+// InBusinessHours reports whether the instant t falls within the given business hours at the port.
+func (p Port) InBusinessHours(t time.Time, hours BusinessHours) (bool, error) {
+	local, err := p.LocalTime(t)
+	if err != nil {
+		return false, err
+	}
+	return hours.Contains(local), nil
+}
@@ -0,0 +1,108 @@
+package domain
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPort_Validate_Timezone(t *testing.T) {
+	tests := []struct {
+		timezone string
+		valid    bool
+	}{
+		{timezone: "", valid: true},
+		{timezone: "Asia/Dubai", valid: true},
+		{timezone: "America/Argentina/Ushuaia", valid: true},
+		{timezone: "UTC", valid: true},
+		{timezone: "America/Argentina"},
+		{timezone: "Local"},
+		{timezone: "Mars/Olympus_Mons"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.timezone, func(t *testing.T) {
+			port := Port{UNLOC: "AEJEA", Name: "Jebel Ali", City: "Dubai", Country: "United Arab Emirates", Timezone: test.timezone}
+			err := port.Validate()
+			if test.valid {
+				assert.NoError(t, err)
+				return
+			}
+			var validationErr *ValidationError
+			assert.ErrorAs(t, err, &validationErr)
+			assert.Equal(t, "timezone", validationErr.Fields[0].Rule)
+		})
+	}
+}
+
+func TestPort_LocalTime(t *testing.T) {
+	port := Port{UNLOC: "NLRTM", Timezone: "Europe/Amsterdam"}
+	instant := time.Date(2024, time.July, 1, 12, 0, 0, 0, time.UTC)
+
+	local, err := port.LocalTime(instant)
+	assert.NoError(t, err)
+	assert.Equal(t, 14, local.Hour())
+	assert.True(t, local.Equal(instant))
+
+	offset, err := port.UTCOffset(instant)
+	assert.NoError(t, err)
+	assert.Equal(t, 2*time.Hour, offset)
+
+	_, err = Port{UNLOC: "AEJEA"}.LocalTime(instant)
+	assert.ErrorIs(t, err, ErrNoTimezone)
+}
+
+func TestPort_Transitions(t *testing.T) {
+	from := time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+	until := from.AddDate(1, 0, 0)
+
+	transitions, err := Port{Timezone: "Europe/Amsterdam"}.Transitions(from, until)
+	assert.NoError(t, err)
+	assert.Len(t, transitions, 2)
+	assert.True(t, transitions[0].At.Equal(time.Date(2024, time.March, 31, 1, 0, 0, 0, time.UTC)))
+	assert.Equal(t, Transition{At: transitions[0].At, Zone: "CEST", UTCOffsetSeconds: 7200, DST: true}, transitions[0])
+	assert.True(t, transitions[1].At.Equal(time.Date(2024, time.October, 27, 1, 0, 0, 0, time.UTC)))
+	assert.Equal(t, "CET", transitions[1].Zone)
+	assert.False(t, transitions[1].DST)
+
+	// Dubai has not observed daylight saving time for decades
+	transitions, err = Port{Timezone: "Asia/Dubai"}.Transitions(from, until)
+	assert.NoError(t, err)
+	assert.Empty(t, transitions)
+}
+
+func TestParseBusinessHours(t *testing.T) {
+	hours, err := ParseBusinessHours("08:30-18:00")
+	assert.NoError(t, err)
+	assert.Equal(t, BusinessHours{Open: 8*time.Hour + 30*time.Minute, Close: 18 * time.Hour}, hours)
+
+	for _, input := range []string{"", "08:30", "8h-18h", "08:30-25:00"} {
+		_, err := ParseBusinessHours(input)
+		assert.Error(t, err, input)
+	}
+}
+
+func TestBusinessHours_Contains(t *testing.T) {
+	night := BusinessHours{Days: []time.Weekday{time.Friday}, Open: 22 * time.Hour, Close: 6 * time.Hour}
+
+	tests := []struct {
+		name     string
+		hours    BusinessHours
+		local    time.Time
+		expected bool
+	}{
+		{name: "weekday", hours: DefaultBusinessHours, local: time.Date(2024, time.July, 3, 9, 0, 0, 0, time.UTC), expected: true},
+		{name: "closing time", hours: DefaultBusinessHours, local: time.Date(2024, time.July, 3, 17, 0, 0, 0, time.UTC)},
+		{name: "weekend", hours: DefaultBusinessHours, local: time.Date(2024, time.July, 6, 12, 0, 0, 0, time.UTC)},
+		{name: "night shift opening day", hours: night, local: time.Date(2024, time.July, 5, 23, 0, 0, 0, time.UTC), expected: true},
+		{name: "night shift past midnight", hours: night, local: time.Date(2024, time.July, 6, 5, 59, 0, 0, time.UTC), expected: true},
+		{name: "night shift before opening", hours: night, local: time.Date(2024, time.July, 5, 5, 0, 0, 0, time.UTC)},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.expected, test.hours.Contains(test.local))
+		})
+	}
+}
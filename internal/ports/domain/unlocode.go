@@ -0,0 +1,71 @@
+package domain
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrInvalidUNLOCODE is returned when parsing an ill-formed UN/LOCODE.
+var ErrInvalidUNLOCODE = errors.New("invalid UN/LOCODE")
+
+// UNLOCODE is a UN/LOCODE, the ISO 3166-1 alpha-2 code of a country followed by three characters
+// identifying a location within it, letters or digits from 2 to 9, e.g. "AEJEA".
+// Codes decoded from JSON are upper-cased, and the ports holding ill-formed codes fail validation.
+type UNLOCODE string
+
+// ParseUNLOCODE parses a UN/LOCODE case-insensitively into its canonical, upper case form.
+func ParseUNLOCODE(s string) (UNLOCODE, error) {
+	code := UNLOCODE(strings.ToUpper(s))
+	if !code.Valid() {
+		return "", fmt.Errorf("%w %q", ErrInvalidUNLOCODE, s)
+	}
+	return code, nil
+}
+
+// Valid reports whether the code is a well-formed UN/LOCODE in canonical form.
+func (c UNLOCODE) Valid() bool {
+	if len(c) != 5 {
+		return false
+	}
+	for i := 0; i < len(c); i++ {
+		switch ch := c[i]; {
+		case ch >= 'A' && ch <= 'Z':
+		case i >= 2 && ch >= '2' && ch <= '9':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// Country returns the ISO 3166-1 alpha-2 code of the country of the location.
+func (c UNLOCODE) Country() string {
+	if len(c) < 2 {
+		return string(c)
+	}
+	return string(c[:2])
+}
+
+// Location returns the code of the location within its country.
+func (c UNLOCODE) Location() string {
+	if len(c) < 2 {
+		return ""
+	}
+	return string(c[2:])
+}
+
+func (c UNLOCODE) String() string {
+	return string(c)
+}
+
+// UnmarshalJSON decodes the code in its canonical form, leaving it to validation to reject it when ill-formed.
+func (c *UNLOCODE) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	*c = UNLOCODE(strings.ToUpper(s))
+	return nil
+}
@@ -0,0 +1,58 @@
+package domain
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseUNLOCODE(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected UNLOCODE
+		wantErr  bool
+	}{
+		{input: "AEJEA", expected: "AEJEA"},
+		{input: "aejea", expected: "AEJEA"},
+		{input: "USNY2", expected: "USNY2"},
+		{input: "12345", wantErr: true},
+		{input: "A1JEA", wantErr: true},
+		{input: "USNY1", wantErr: true},
+		{input: "USNY0", wantErr: true},
+		{input: "ae jea", wantErr: true},
+		{input: "AEJE", wantErr: true},
+		{input: "", wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			code, err := ParseUNLOCODE(test.input)
+			if test.wantErr {
+				assert.ErrorIs(t, err, ErrInvalidUNLOCODE)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, test.expected, code)
+		})
+	}
+}
+
+func TestUNLOCODE_Parts(t *testing.T) {
+	code := UNLOCODE("AEJEA")
+	assert.Equal(t, "AE", code.Country())
+	assert.Equal(t, "JEA", code.Location())
+}
+
+func TestUNLOCODE_UnmarshalJSON(t *testing.T) {
+	var port Port
+	err := json.Unmarshal([]byte(`{"unloc": "aejea", "name": "Jebel Ali", "city": "Dubai", "country": "United Arab Emirates", "unlocs": ["aejea", "ae jea"]}`), &port)
+	assert.NoError(t, err)
+	assert.Equal(t, UNLOCODE("AEJEA"), port.UNLOC)
+	assert.Equal(t, []UNLOCODE{"AEJEA", "AE JEA"}, port.UNLOCs)
+
+	// Ill-formed codes are decoded as is, and rejected by validation
+	var validationErr *ValidationError
+	assert.ErrorAs(t, port.Validate(), &validationErr)
+	assert.Equal(t, "unlocode", validationErr.Fields[0].Rule)
+}
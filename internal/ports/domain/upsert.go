@@ -0,0 +1,24 @@
+package domain
+
+import "errors"
+
+// UpsertStatus describes the effect of an upsert on the stored port.
+type UpsertStatus int
+
+const (
+	// Inserted means that the port was not stored before.
+	Inserted UpsertStatus = iota + 1
+	// Updated means that the stored port was replaced by a different one.
+	Updated
+	// Unchanged means that the stored port was already identical, so it was not written.
+	Unchanged
+)
+
+// UpsertResult is the outcome of a single upsert within a batch.
+type UpsertResult struct {
+	Status UpsertStatus
+	Err    error
+}
+
+// ErrPortChanged is returned by conditional upserts when the stored port changed since it was read.
+var ErrPortChanged = errors.New("port changed since it was read")
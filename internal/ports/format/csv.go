@@ -0,0 +1,189 @@
+package format
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"ports-service/internal/ports/domain"
+)
+
+// Columns of the UN/LOCODE code list CSV release.
+const (
+	csvChange = iota
+	csvCountry
+	csvLocation
+	csvName
+	csvNameWoDiacritics
+	csvSubdivision
+	csvFunction
+	csvStatus
+	csvDate
+	csvIATA
+	csvCoordinates
+	csvRemarks
+	csvColumns
+)
+
+// csvDecoder decodes the ports of a UN/LOCODE code list CSV release.
+//
+// The release lists the countries, each followed by its locations. Only the locations
+// functioning as ports are decoded, while the locations marked for deletion and the
+// references to other entries are skipped.
+type csvDecoder struct {
+	reader *csv.Reader
+	// countries holds the names of the countries listed so far, by code.
+	countries map[string]string
+}
+
+func newCSVDecoder(reader io.Reader) *csvDecoder {
+	r := csv.NewReader(reader)
+	r.FieldsPerRecord = -1
+	r.LazyQuotes = true
+
+	return &csvDecoder{
+		reader:    r,
+		countries: make(map[string]string),
+	}
+}
+
+func (d *csvDecoder) Next() (Record, error) {
+	for {
+		offset := d.reader.InputOffset()
+		fields, err := d.reader.Read()
+		if err != nil {
+			return Record{}, err
+		}
+		if len(fields) < csvColumns {
+			line, _ := d.reader.FieldPos(0)
+			return Record{}, fmt.Errorf("line %d: expected %d columns, got %d", line, csvColumns, len(fields))
+		}
+		for i, field := range fields {
+			fields[i] = toUTF8(strings.TrimSpace(field))
+		}
+
+		country, location := fields[csvCountry], fields[csvLocation]
+		switch {
+		case location == "":
+			// Country entries are named like ".UNITED ARAB EMIRATES"
+			d.countries[country] = titleCase(strings.TrimPrefix(fields[csvName], "."))
+			continue
+		case fields[csvChange] == "X" || fields[csvChange] == "=":
+			continue
+		case !strings.HasPrefix(fields[csvFunction], "1"):
+			continue
+		}
+
+		return Record{Port: d.port(fields), Offset: offset}, nil
+	}
+}
+
+// port maps the fields of a location entry onto a port. Malformed coordinates are mapped onto
+// a malformed point, so that validation rejects the port rather than the whole release.
+func (d *csvDecoder) port(fields []string) domain.Port {
+	country := fields[csvCountry]
+	unloc := domain.UNLOCODE(strings.ToUpper(country + fields[csvLocation]))
+
+	port := domain.Port{
+		UNLOC:    unloc,
+		Name:     fields[csvName],
+		City:     fields[csvName],
+		Country:  d.countries[country],
+		Province: fields[csvSubdivision],
+		UNLOCs:   []domain.UNLOCODE{unloc},
+	}
+	if port.Country == "" {
+		port.Country = country
+	}
+	if alias := fields[csvNameWoDiacritics]; alias != "" && alias != port.Name {
+		port.Alias = []string{alias}
+	}
+
+	if value := fields[csvCoordinates]; value != "" {
+		coordinates, err := parseCoordinates(value)
+		if err != nil {
+			malformed := domain.MalformedGeoPoint(value)
+			coordinates = &malformed
+		}
+		port.Coordinates = coordinates
+	}
+
+	return port
+}
+
+// parseCoordinates parses UN/LOCODE coordinates such as "2523N 05518E", made of the
+// latitude and the longitude in degrees and minutes.
+func parseCoordinates(value string) (*domain.GeoPoint, error) {
+	parts := strings.Fields(value)
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("invalid coordinates %q", value)
+	}
+
+	lat, err := parseDegrees(parts[0], 2, 'N', 'S')
+	if err != nil {
+		return nil, fmt.Errorf("invalid latitude in coordinates %q: %w", value, err)
+	}
+	lon, err := parseDegrees(parts[1], 3, 'E', 'W')
+	if err != nil {
+		return nil, fmt.Errorf("invalid longitude in coordinates %q: %w", value, err)
+	}
+
+	return &domain.GeoPoint{Lat: lat, Lon: lon}, nil
+}
+
+// parseDegrees parses an angle made of the given number of degree digits, two minute digits
+// and a hemisphere letter, which is negative for the second hemisphere.
+func parseDegrees(value string, digits int, positive, negative byte) (float64, error) {
+	if len(value) != digits+3 {
+		return 0, fmt.Errorf("expected %d characters, got %d", digits+3, len(value))
+	}
+
+	degrees, err := strconv.Atoi(value[:digits])
+	if err != nil {
+		return 0, err
+	}
+	minutes, err := strconv.Atoi(value[digits : digits+2])
+	if err != nil {
+		return 0, err
+	}
+	if minutes >= 60 {
+		return 0, fmt.Errorf("minutes out of range: %d", minutes)
+	}
+
+	angle := float64(degrees) + float64(minutes)/60
+	switch value[digits+2] {
+	case positive:
+		return angle, nil
+	case negative:
+		return -angle, nil
+	default:
+		return 0, fmt.Errorf("unknown hemisphere %q", value[digits+2])
+	}
+}
+
+// toUTF8 converts the value from Latin-1, the encoding of older releases, unless it is valid UTF-8.
+func toUTF8(value string) string {
+	if utf8.ValidString(value) {
+		return value
+	}
+
+	runes := make([]rune, len(value))
+	for i := 0; i < len(value); i++ {
+		runes[i] = rune(value[i])
+	}
+	return string(runes)
+}
+
+// titleCase capitalises the first letter of each word of the value, e.g. "UNITED ARAB EMIRATES"
+// becomes "United Arab Emirates".
+func titleCase(value string) string {
+	words := strings.Fields(strings.ToLower(value))
+	for i, word := range words {
+		r, size := utf8.DecodeRuneInString(word)
+		words[i] = strings.ToUpper(string(r)) + word[size:]
+	}
+	return strings.Join(words, " ")
+}
@@ -0,0 +1,103 @@
+package format_test
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"ports-service/internal/ports/domain"
+	"ports-service/internal/ports/format"
+)
+
+var sampleCSV = `,"AE","",".UNITED ARAB EMIRATES","","","","","","","",""
+,"AE","AJM","Ajman","Ajman","AJ","1-------","AI","9601","","2525N 05527E",""
+,"AE","AUH","Abu Dhabi","Abu Dhabi","AZ","1-345---","AI","0107","","2428N 05422E",""
+,"AE","DHF","Al Dhafra","Al Dhafra","AZ","--3-----","AI","0601","","2414N 05432E",""
+X,"AE","XXX","Removed","Removed","","1-------","","","","",""
+,"AR","BUE","Buenos Aires","Buenos Aires","B","12345---","AI","9601","","3436S 05827W",""
+,"BR","SSZ","Santos","Santos","SP","1-------","AI","9601","","",""
+`
+
+func TestCSVDecoder(t *testing.T) {
+	dec, err := format.NewDecoder(format.Auto, strings.NewReader(sampleCSV))
+	assert.NoError(t, err)
+
+	var ports []domain.Port
+	for {
+		rec, err := dec.Next()
+		if err == io.EOF {
+			break
+		}
+		assert.NoError(t, err)
+		ports = append(ports, rec.Port)
+	}
+
+	// Only the ports are decoded, skipping the other locations and the deleted entries
+	assert.Len(t, ports, 4)
+	assert.Equal(t, domain.Port{
+		UNLOC:       "AEAJM",
+		Name:        "Ajman",
+		City:        "Ajman",
+		Country:     "United Arab Emirates",
+		Coordinates: &domain.GeoPoint{Lat: 25 + 25.0/60, Lon: 55 + 27.0/60},
+		Province:    "AJ",
+		UNLOCs:      []domain.UNLOCODE{"AEAJM"},
+	}, ports[0])
+	assert.Equal(t, domain.UNLOCODE("AEAUH"), ports[1].UNLOC)
+
+	// Southern and western coordinates are negative, and the country name falls back to its code
+	assert.Equal(t, domain.UNLOCODE("ARBUE"), ports[2].UNLOC)
+	assert.Equal(t, "AR", ports[2].Country)
+	assert.Equal(t, &domain.GeoPoint{Lat: -(34 + 36.0/60), Lon: -(58 + 27.0/60)}, ports[2].Coordinates)
+
+	assert.Equal(t, domain.UNLOCODE("BRSSZ"), ports[3].UNLOC)
+	assert.Nil(t, ports[3].Coordinates)
+}
+
+func TestCSVDecoder_NameWithoutDiacritics(t *testing.T) {
+	// Latin-1 encoded name, as found in older releases
+	input := ",\"AE\",\"\",\".UNITED ARAB EMIRATES\",\"\",\"\",\"\",\"\",\"\",\"\",\"\",\"\"\n" +
+		",\"AE\",\"AZA\",\"Al Z\xe1hir\",\"Al Zahir\",\"\",\"1-------\",\"\",\"\",\"\",\"\",\"\"\n"
+
+	dec, err := format.NewDecoder(format.CSV, strings.NewReader(input))
+	assert.NoError(t, err)
+
+	rec, err := dec.Next()
+	assert.NoError(t, err)
+	assert.Equal(t, "Al Záhir", rec.Port.Name)
+	assert.Equal(t, []string{"Al Zahir"}, rec.Port.Alias)
+}
+
+func TestCSVDecoder_InvalidCoordinates(t *testing.T) {
+	tests := []struct {
+		name        string
+		coordinates string
+	}{
+		{name: "Missing longitude", coordinates: "2525N"},
+		{name: "Swapped latitude and longitude", coordinates: "05527E 2525N"},
+		{name: "Minutes out of range", coordinates: "2525N 05575E"},
+		{name: "Unknown hemisphere", coordinates: "2525N 05527N"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			input := `,"AE","AJM","Ajman","Ajman","AJ","1-------","AI","9601","","` + test.coordinates + `",""`
+
+			dec, err := format.NewDecoder(format.CSV, strings.NewReader(input))
+			assert.NoError(t, err)
+
+			// The port is decoded with malformed coordinates, which validation rejects
+			rec, err := dec.Next()
+			assert.NoError(t, err)
+			assert.Equal(t, domain.UNLOCODE("AEAJM"), rec.Port.UNLOC)
+			malformed := domain.MalformedGeoPoint(test.coordinates)
+			assert.Equal(t, &malformed, rec.Port.Coordinates)
+
+			var validationErr *domain.ValidationError
+			assert.ErrorAs(t, rec.Port.Validate(), &validationErr)
+			assert.Equal(t, domain.RuleCoordinates, validationErr.Fields[0].Rule)
+		})
+	}
+}
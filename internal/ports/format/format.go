@@ -0,0 +1,109 @@
+// Package format decodes ports from the supported input formats.
+package format
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"ports-service/internal/ports/domain"
+)
+
+// Format is an input format of ports.
+type Format string
+
+const (
+	// Auto detects the format from the beginning of the input.
+	Auto Format = ""
+	// Object is a single JSON object holding the ports keyed by their UNLOC.
+	Object Format = "object"
+	// Array is a JSON array of ports, each holding its UNLOC.
+	Array Format = "array"
+	// NDJSON is a stream of newline delimited JSON ports, each holding its UNLOC.
+	NDJSON Format = "ndjson"
+	// CSV is a UN/LOCODE code list CSV release.
+	CSV Format = "csv"
+)
+
+// detectionSize is the number of bytes looked at to detect the format of an input.
+const detectionSize = 4096
+
+// Record is a port decoded from an input.
+type Record struct {
+	Port domain.Port
+	// Offset is the byte offset of the record in the input.
+	Offset int64
+	// Raw is the record as found in the input, for the JSON formats only.
+	Raw json.RawMessage
+}
+
+// Decoder decodes the ports of an input one at a time.
+type Decoder interface {
+	// Next returns the next record of the input, or io.EOF once the input is exhausted.
+	Next() (Record, error)
+}
+
+// NewDecoder returns a decoder of the given format reading from the reader.
+func NewDecoder(format Format, reader io.Reader) (Decoder, error) {
+	if format == Auto {
+		buffered := bufio.NewReaderSize(reader, detectionSize)
+		detected, err := detect(buffered)
+		if err != nil {
+			return nil, err
+		}
+		format, reader = detected, buffered
+	}
+
+	switch format {
+	case Object:
+		return newObjectDecoder(reader), nil
+	case Array:
+		return newArrayDecoder(reader), nil
+	case NDJSON:
+		return newNDJSONDecoder(reader), nil
+	case CSV:
+		return newCSVDecoder(reader), nil
+	default:
+		return nil, fmt.Errorf("unsupported format %q", format)
+	}
+}
+
+// detect tells the format of the input from its first bytes, without consuming them.
+// A leading object is told apart from NDJSON by its first value: ports keyed by UNLOC are
+// objects, while the first value of an NDJSON port is one of its fields. Any other input
+// whose first line holds commas is considered to be CSV.
+func detect(reader *bufio.Reader) (Format, error) {
+	head, err := reader.Peek(detectionSize)
+	if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+		return "", err
+	}
+
+	head = bytes.TrimLeft(head, " \t\r\n")
+	if len(head) == 0 {
+		return "", io.ErrUnexpectedEOF
+	}
+
+	switch head[0] {
+	case '[':
+		return Array, nil
+	case '{':
+		dec := json.NewDecoder(bytes.NewReader(head))
+		for i := 0; i < 2; i++ {
+			// Skip the opening brace and the first key
+			if _, err := dec.Token(); err != nil {
+				return Object, nil
+			}
+		}
+		if t, err := dec.Token(); err == nil && t != json.Delim('{') {
+			return NDJSON, nil
+		}
+		return Object, nil
+	default:
+		if line, _, _ := bytes.Cut(head, []byte("\n")); bytes.Contains(line, []byte(",")) {
+			return CSV, nil
+		}
+		return "", fmt.Errorf("expected a JSON object, array, newline delimited ports or CSV, got %q", head[0])
+	}
+}
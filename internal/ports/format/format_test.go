@@ -0,0 +1,121 @@
+package format_test
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"ports-service/internal/ports/domain"
+	"ports-service/internal/ports/format"
+)
+
+func TestDecoder(t *testing.T) {
+	tests := []struct {
+		name   string
+		format format.Format
+		input  string
+		// second is the beginning of the second record of the input
+		second string
+	}{
+		{
+			name:   "Object",
+			format: format.Object,
+			input:  `{"AEJEA": {"name": "Jebel Ali"}, "AEJED": {"name": "Jebel Dhanna"}}`,
+			second: `"AEJED"`,
+		},
+		{
+			name:   "Detected object",
+			format: format.Auto,
+			// UNLOCs are decoded in canonical form
+			input:  ` {"aejea": {"name": "Jebel Ali"}, "AEJED": {"name": "Jebel Dhanna"}}`,
+			second: `"AEJED"`,
+		},
+		{
+			name:   "Array",
+			format: format.Array,
+			input:  `[{"unloc": "AEJEA", "name": "Jebel Ali"}, {"unloc": "AEJED", "name": "Jebel Dhanna"}]`,
+			second: `{"unloc": "AEJED"`,
+		},
+		{
+			name:   "Detected array",
+			format: format.Auto,
+			input:  "\n[{\"unloc\": \"AEJEA\", \"name\": \"Jebel Ali\"}, {\"unloc\": \"AEJED\", \"name\": \"Jebel Dhanna\"}]",
+			second: `{"unloc": "AEJED"`,
+		},
+		{
+			name:   "NDJSON",
+			format: format.NDJSON,
+			input:  "{\"unloc\": \"AEJEA\", \"name\": \"Jebel Ali\"}\n{\"unloc\": \"AEJED\", \"name\": \"Jebel Dhanna\"}\n",
+			second: `{"unloc": "AEJED"`,
+		},
+		{
+			name:   "Detected NDJSON",
+			format: format.Auto,
+			input:  "{\"unloc\": \"AEJEA\", \"name\": \"Jebel Ali\"}\n{\"unloc\": \"aeJed\", \"name\": \"Jebel Dhanna\"}",
+			second: `{"unloc": "aeJed"`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dec, err := format.NewDecoder(test.format, strings.NewReader(test.input))
+			assert.NoError(t, err)
+
+			first, err := dec.Next()
+			assert.NoError(t, err)
+			assert.Equal(t, domain.UNLOCODE("AEJEA"), first.Port.UNLOC)
+			assert.Equal(t, "Jebel Ali", first.Port.Name)
+
+			second, err := dec.Next()
+			assert.NoError(t, err)
+			assert.Equal(t, domain.UNLOCODE("AEJED"), second.Port.UNLOC)
+			assert.Equal(t, "Jebel Dhanna", second.Port.Name)
+			assert.Greater(t, second.Offset, first.Offset)
+			// Offsets point at the records, past the separators preceding them
+			assert.True(t, strings.HasPrefix(test.input[second.Offset:], test.second), test.input[second.Offset:])
+
+			_, err = dec.Next()
+			assert.ErrorIs(t, err, io.EOF)
+		})
+	}
+}
+
+func TestDecoder_Errors(t *testing.T) {
+	tests := []struct {
+		name             string
+		format           format.Format
+		input            string
+		expectedErrorMsg string
+	}{
+		{
+			name:             "Undetectable input",
+			format:           format.Auto,
+			input:            `"AEJEA"`,
+			expectedErrorMsg: "expected a JSON object, array, newline delimited ports or CSV",
+		},
+		{
+			name:             "Array instead of object",
+			format:           format.Object,
+			input:            `[{"unloc": "AEJEA"}]`,
+			expectedErrorMsg: "expected {, got [",
+		},
+		{
+			name:             "Unsupported format",
+			format:           format.Format("xml"),
+			input:            `<ports/>`,
+			expectedErrorMsg: `unsupported format "xml"`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dec, err := format.NewDecoder(test.format, strings.NewReader(test.input))
+			if err == nil {
+				_, err = dec.Next()
+			}
+			assert.ErrorContains(t, err, test.expectedErrorMsg)
+		})
+	}
+}
@@ -0,0 +1,127 @@
+package format
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"ports-service/internal/ports/domain"
+)
+
+// objectDecoder decodes a single JSON object holding the ports keyed by their UNLOC.
+type objectDecoder struct {
+	dec     *json.Decoder
+	started bool
+}
+
+func newObjectDecoder(reader io.Reader) *objectDecoder {
+	return &objectDecoder{dec: json.NewDecoder(reader)}
+}
+
+func (d *objectDecoder) Next() (Record, error) {
+	if !d.started {
+		if err := expectDelim(d.dec, '{'); err != nil {
+			return Record{}, err
+		}
+		d.started = true
+	}
+	if !d.dec.More() {
+		return Record{}, io.EOF
+	}
+
+	t, err := d.dec.Token()
+	if err != nil {
+		return Record{}, err
+	}
+	key := t.(string)
+	offset := tokenStart(d.dec, key)
+
+	rec, err := decodeRecord(d.dec)
+	if err != nil {
+		return Record{}, err
+	}
+
+	// Keys are canonicalized as the UNLOCs held by ports are when decoded
+	rec.Port.UNLOC = domain.UNLOCODE(strings.ToUpper(key))
+	rec.Offset = offset
+	return rec, nil
+}
+
+// arrayDecoder decodes a JSON array of ports.
+type arrayDecoder struct {
+	dec     *json.Decoder
+	started bool
+}
+
+func newArrayDecoder(reader io.Reader) *arrayDecoder {
+	return &arrayDecoder{dec: json.NewDecoder(reader)}
+}
+
+func (d *arrayDecoder) Next() (Record, error) {
+	if !d.started {
+		if err := expectDelim(d.dec, '['); err != nil {
+			return Record{}, err
+		}
+		d.started = true
+	}
+	if !d.dec.More() {
+		return Record{}, io.EOF
+	}
+
+	return decodeRecord(d.dec)
+}
+
+// ndjsonDecoder decodes newline delimited JSON ports.
+type ndjsonDecoder struct {
+	dec *json.Decoder
+}
+
+func newNDJSONDecoder(reader io.Reader) *ndjsonDecoder {
+	return &ndjsonDecoder{dec: json.NewDecoder(reader)}
+}
+
+func (d *ndjsonDecoder) Next() (Record, error) {
+	if !d.dec.More() {
+		return Record{}, io.EOF
+	}
+
+	return decodeRecord(d.dec)
+}
+
+// decodeRecord decodes the next value of the decoder as a port, keeping the value as is.
+func decodeRecord(dec *json.Decoder) (Record, error) {
+	var raw json.RawMessage
+	if err := dec.Decode(&raw); err != nil {
+		return Record{}, err
+	}
+	// The input offset is at the end of the value, while before it would be at the separator preceding it
+	offset := dec.InputOffset() - int64(len(raw))
+
+	var port domain.Port
+	if err := json.Unmarshal(raw, &port); err != nil {
+		return Record{}, err
+	}
+
+	return Record{Port: port, Offset: offset, Raw: raw}, nil
+}
+
+// tokenStart returns the offset of the string token just read by the decoder, which is exact unless
+// the string holds escape sequences.
+func tokenStart(dec *json.Decoder, value string) int64 {
+	// Strings always marshal
+	quoted, _ := json.Marshal(value)
+	return dec.InputOffset() - int64(len(quoted))
+}
+
+// expectDelim consumes the next token of the decoder, which must be the given delimiter.
+func expectDelim(dec *json.Decoder, delim json.Delim) error {
+	t, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if t != delim {
+		return fmt.Errorf("expected %v, got %v", delim, t)
+	}
+	return nil
+}
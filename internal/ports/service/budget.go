@@ -0,0 +1,88 @@
+package service
+
+import (
+	"sync"
+
+	"ports-service/internal/ports/format"
+)
+
+// inFlightShare is the share of the memory budget, as a divisor, taken by the records going through
+// the pipeline. The rest is left to the decoder, the repository client and the garbage collector.
+const inFlightShare = 4
+
+// keptShare is the share of the memory budget, as a divisor, taken by the ports kept by dry runs
+// to merge duplicates into, see writePorts.
+const keptShare = 4
+
+// recordOverhead approximates the memory taken by a record on top of its raw JSON: the decoded
+// port, its strings and the pipeline bookkeeping.
+const recordOverhead = 512
+
+// inFlight bounds the memory taken by the records going through the pipeline, from the moment they
+// are decoded until their outcome is recorded: once their estimated size reaches the limit, the decoder
+// waits for records to be written. It is what slows decoding down when the write queues or the batches
+// in flight grow, e.g. because the repository is slower than the decoder.
+// A nil inFlight does not limit anything.
+type inFlight struct {
+	mu    sync.Mutex
+	cond  *sync.Cond
+	limit int64
+	used  int64
+	// minRecords is the number of records always let through whatever their size, see minInFlight.
+	minRecords int
+	records    int
+}
+
+// minInFlight returns the number of records the pipeline must be able to hold for its writers to make
+// progress: with fewer, the shard and validation queues of each worker and its batch, which is only
+// written once full, could take all the records in flight while no batch is full, and the decoder would
+// wait for writes that never come.
+func minInFlight(workers, batchSize int) int {
+	return workers * (batchSize + 2*stageBufferSize + 1)
+}
+
+// newInFlight returns an inFlight limiting records to the given size, though always letting minRecords
+// records through, or nil when the limit is not positive.
+func newInFlight(limit int64, minRecords int) *inFlight {
+	if limit <= 0 {
+		return nil
+	}
+	f := &inFlight{limit: limit, minRecords: minRecords}
+	f.cond = sync.NewCond(&f.mu)
+	return f
+}
+
+// acquire waits until the record of the given size fits in the limit, and takes its size.
+// A record larger than the limit is let through once fewer than minRecords records are in flight.
+func (f *inFlight) acquire(size int64) {
+	if f == nil {
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for f.records >= f.minRecords && f.used+size > f.limit {
+		f.cond.Wait()
+	}
+	f.used += size
+	f.records++
+}
+
+// release gives back the size of a record whose outcome was recorded.
+func (f *inFlight) release(size int64) {
+	if f == nil {
+		return
+	}
+
+	f.mu.Lock()
+	f.used -= size
+	f.records--
+	f.mu.Unlock()
+	f.cond.Broadcast()
+}
+
+// recordSize estimates the memory taken by the record while it goes through the pipeline.
+func recordSize(rec format.Record) int64 {
+	// The raw JSON is held along with the decoded port, which takes about as much
+	return 2*int64(len(rec.Raw)) + recordOverhead
+}
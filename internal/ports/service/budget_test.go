@@ -0,0 +1,177 @@
+//go:build !race
+
+package service_test
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"runtime"
+	"runtime/metrics"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"ports-service/internal/ports/domain"
+	"ports-service/internal/ports/service"
+)
+
+// discardPortRepository counts the ports written to it without holding them, as a repository
+// holding millions of ports would not fit in any memory budget.
+type discardPortRepository struct {
+	written atomic.Int64
+}
+
+func (r *discardPortRepository) GetPortByUNLOC(context.Context, domain.UNLOCODE) (*domain.Port, error) {
+	return nil, nil
+}
+
+func (r *discardPortRepository) UpsertPort(context.Context, domain.Port) (domain.UpsertStatus, error) {
+	r.written.Add(1)
+	return domain.Inserted, nil
+}
+
+func (r *discardPortRepository) UpsertPorts(_ context.Context, ports []domain.Port) []domain.UpsertResult {
+	// Slow writes make the pipeline fill up, which the budget must hold back
+	time.Sleep(100 * time.Microsecond)
+	r.written.Add(int64(len(ports)))
+	results := make([]domain.UpsertResult, len(ports))
+	for i := range results {
+		results[i].Status = domain.Inserted
+	}
+	return results
+}
+
+func (r *discardPortRepository) DeletePorts(context.Context, []domain.UNLOCODE) error {
+	return nil
+}
+
+func (r *discardPortRepository) ScanUNLOCs(context.Context, func([]domain.UNLOCODE) error) error {
+	return nil
+}
+
+// syntheticPorts generates n ports as NDJSON on the fly, so that the input itself takes no memory.
+type syntheticPorts struct {
+	n, next int
+	pending []byte
+}
+
+func (r *syntheticPorts) Read(p []byte) (int, error) {
+	read := 0
+	for read < len(p) {
+		if len(r.pending) == 0 {
+			if r.next == r.n {
+				break
+			}
+			unloc := syntheticUNLOC(r.next)
+			r.pending = fmt.Appendf(r.pending[:0],
+				`{"unloc": "%s", "name": "Port %d", "city": "City %d", "country": "%s", "coordinates": [55.0272904, 24.9857145]}`+"\n",
+				unloc, r.next, r.next, unloc[:2])
+			r.next++
+		}
+		n := copy(p[read:], r.pending)
+		r.pending = r.pending[n:]
+		read += n
+	}
+	if read == 0 {
+		return 0, io.EOF
+	}
+	return read, nil
+}
+
+// TestPortService_LoadPorts_MemoryBudget loads several million ports through a slow repository and checks
+// that the heap stays within the budget. It does not build with the race detector, which slows imports
+// down tenfold and inflates the heap.
+func TestPortService_LoadPorts_MemoryBudget(t *testing.T) {
+	if testing.Short() {
+		t.Skip("loads several million ports")
+	}
+
+	const (
+		ports  = 3_000_000
+		budget = 200 << 20
+	)
+
+	runtime.GC()
+	peak := trackPeakHeap(t)
+
+	repo := &discardPortRepository{}
+	portService := service.NewPortService(repo, service.WithMemoryBudget(budget))
+
+	report, err := portService.LoadPorts(context.Background(), &syntheticPorts{n: ports})
+	assert.NoError(t, err)
+	assert.Equal(t, ports, report.Inserted)
+	assert.Equal(t, int64(ports), repo.written.Load())
+
+	heap := peak()
+	t.Logf("peak heap: %dMB for %d ports", heap>>20, ports)
+	assert.LessOrEqual(t, heap, uint64(budget))
+}
+
+// TestPortService_LoadPorts_DryRunMergeMemoryBudget checks that a dry run merging duplicates, which keeps ports
+// to merge them into, stays within the budget as well.
+func TestPortService_LoadPorts_DryRunMergeMemoryBudget(t *testing.T) {
+	if testing.Short() {
+		t.Skip("loads several million ports")
+	}
+
+	const (
+		ports  = 1_000_000
+		budget = 200 << 20
+	)
+
+	runtime.GC()
+	peak := trackPeakHeap(t)
+
+	portService := service.NewPortService(&discardPortRepository{},
+		service.WithMemoryBudget(budget),
+		service.WithDryRun(),
+		service.WithDuplicatePolicy(service.MergeDuplicates),
+	)
+
+	report, err := portService.LoadPorts(context.Background(), &syntheticPorts{n: ports})
+	assert.NoError(t, err)
+	assert.Equal(t, ports, report.Valid)
+
+	heap := peak()
+	t.Logf("peak heap: %dMB for %d ports", heap>>20, ports)
+	assert.LessOrEqual(t, heap, uint64(budget))
+}
+
+// trackPeakHeap samples the heap until the returned function is called, which returns its peak size.
+func trackPeakHeap(t *testing.T) func() uint64 {
+	t.Helper()
+
+	sample := []metrics.Sample{{Name: "/memory/classes/heap/objects:bytes"}}
+	var (
+		peak uint64
+		wg   sync.WaitGroup
+	)
+	done := make(chan struct{})
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(time.Millisecond)
+		defer ticker.Stop()
+		for {
+			metrics.Read(sample)
+			if heap := sample[0].Value.Uint64(); heap > peak {
+				peak = heap
+			}
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	return func() uint64 {
+		close(done)
+		wg.Wait()
+		return peak
+	}
+}
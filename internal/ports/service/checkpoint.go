@@ -0,0 +1,146 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"ports-service/internal/ports/domain"
+)
+
+// checkpointInterval is the number of committed ports between two checkpoint saves.
+const checkpointInterval = 1000
+
+// checkpointTimeout is the time given to checkpoints to be saved or deleted.
+const checkpointTimeout = 5 * time.Second
+
+// checkpointer tracks the records committed by an import and persists its progress,
+// so that an interrupted import of the same source can skip them.
+// A nil checkpointer disables checkpointing.
+type checkpointer struct {
+	repo       CheckpointRepository
+	checkpoint domain.Checkpoint
+	resumed    int64
+
+	// next is the sequence number of the first record not committed yet.
+	next int64
+	// committed holds the offsets of the records committed out of order, by sequence number.
+	committed map[int64]int64
+	// pinned is set once a write fails, the checkpoint does not move past it anymore.
+	pinned bool
+	// pinRejected makes rejected records pin the checkpoint as well, see newCheckpointer.
+	pinRejected bool
+	// unsaved is the number of records committed since the last save.
+	unsaved int
+	err     error
+}
+
+// newCheckpointer loads the checkpoint of the source, discarding it when the source changed since.
+// It returns nil when the source cannot be checkpointed or the repository does not support it.
+// Rejected records pin the checkpoint when the error policy counts them, so that the next import of
+// an aborted one, or of an interrupted one, goes through them again rather than skipping them.
+func newCheckpointer(ctx context.Context, repo PortRepository, src Source, dryRun bool, policy ErrorPolicy) (*checkpointer, error) {
+	checkpoints, ok := repo.(CheckpointRepository)
+	if !ok || dryRun || src.Name == "" || src.Fingerprint == "" {
+		return nil, nil
+	}
+
+	c := &checkpointer{
+		repo:        checkpoints,
+		checkpoint:  domain.Checkpoint{Source: src.Name, Fingerprint: src.Fingerprint},
+		committed:   make(map[int64]int64),
+		pinRejected: policy != BestEffort(),
+	}
+
+	checkpoint, err := checkpoints.GetCheckpoint(ctx, src.Name)
+	switch {
+	case err != nil:
+		return nil, err
+	case checkpoint == nil:
+	case checkpoint.Fingerprint == src.Fingerprint:
+		c.checkpoint.Offset = checkpoint.Offset
+		c.resumed = checkpoint.Offset
+	default:
+		// The source changed since the checkpoint was taken, so its offset is meaningless
+		if err := checkpoints.DeleteCheckpoint(ctx, src.Name); err != nil {
+			return nil, err
+		}
+	}
+
+	return c, nil
+}
+
+// resumeOffset returns the offset before which the records were committed by a previous import.
+func (c *checkpointer) resumeOffset() int64 {
+	if c == nil {
+		return 0
+	}
+	return c.resumed
+}
+
+// commit records the outcome of a record and saves a checkpoint every checkpointInterval records.
+// Records that failed to be written or were dropped pin the checkpoint, so that a resumed import retries them,
+// as do the rejected records counted by the error policy.
+func (c *checkpointer) commit(ctx context.Context, result importResult) {
+	if c == nil || c.pinned {
+		return
+	}
+	if result.dropped || result.err != nil && (result.stage == StageWrite || c.pinRejected) {
+		c.pinned = true
+		return
+	}
+
+	c.committed[result.seq] = result.offset
+	for {
+		offset, ok := c.committed[c.next]
+		if !ok {
+			break
+		}
+		delete(c.committed, c.next)
+		c.next++
+		// Any record starting after the committed one is still to be imported
+		c.checkpoint.Offset = offset + 1
+		c.unsaved++
+	}
+
+	if c.unsaved >= checkpointInterval {
+		c.save(ctx)
+	}
+}
+
+// finish deletes the checkpoint of a completed import, or saves the progress of an interrupted one.
+// It returns the first error met while persisting checkpoints.
+func (c *checkpointer) finish(ctx context.Context, completed bool) error {
+	if c == nil {
+		return nil
+	}
+
+	if completed {
+		ctx, cancel := checkpointContext(ctx)
+		defer cancel()
+		if err := c.repo.DeleteCheckpoint(ctx, c.checkpoint.Source); err != nil && c.err == nil {
+			c.err = err
+		}
+	} else if c.unsaved > 0 {
+		c.save(ctx)
+	}
+	return c.err
+}
+
+func (c *checkpointer) save(ctx context.Context) {
+	ctx, cancel := checkpointContext(ctx)
+	defer cancel()
+	if err := c.repo.SaveCheckpoint(ctx, c.checkpoint); err != nil {
+		if c.err == nil {
+			c.err = err
+		}
+		return
+	}
+	c.unsaved = 0
+}
+
+// checkpointContext returns the context checkpoints are persisted with. It is not canceled along with
+// the given one, as the progress of an import canceled by a shutdown, or whose writes were canceled by
+// the drain timeout, is precisely what must be saved. It times out after checkpointTimeout instead.
+func checkpointContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(detachedContext{ctx}, checkpointTimeout)
+}
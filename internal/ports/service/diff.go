@@ -0,0 +1,406 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"ports-service/internal/ports/domain"
+	"ports-service/internal/ports/format"
+)
+
+// defaultDiffPartitionSize is the number of ports of a source held in memory at once by a diff.
+// Larger sources are compared a partition of UNLOCs at a time, reading them once per partition.
+const defaultDiffPartitionSize = 100_000
+
+// ChangeKind tells how a port of a source differs from its baseline.
+type ChangeKind string
+
+const (
+	// PortAdded is a port of the source missing from the baseline.
+	PortAdded ChangeKind = "added"
+	// PortRemoved is a port of the baseline missing from the source.
+	PortRemoved ChangeKind = "removed"
+	// PortModified is a port whose fields differ between the baseline and the source.
+	PortModified ChangeKind = "modified"
+)
+
+// PortChange describes how a port differs between a baseline and a source.
+type PortChange struct {
+	UNLOC domain.UNLOCODE `json:"unloc"`
+	Kind  ChangeKind      `json:"kind"`
+	// Fields holds the differing fields of a modified port.
+	Fields []domain.FieldChange `json:"fields,omitempty"`
+}
+
+// String formats the change for display.
+func (c PortChange) String() string {
+	var b strings.Builder
+	switch c.Kind {
+	case PortAdded:
+		fmt.Fprintf(&b, "+ %s\n", c.UNLOC)
+	case PortRemoved:
+		fmt.Fprintf(&b, "- %s\n", c.UNLOC)
+	default:
+		fmt.Fprintf(&b, "~ %s\n", c.UNLOC)
+		for _, field := range c.Fields {
+			// Fields hold JSON values, so they always marshal
+			old, _ := json.Marshal(field.Old)
+			new, _ := json.Marshal(field.New)
+			fmt.Fprintf(&b, "    %s: %s -> %s\n", field.Field, old, new)
+		}
+	}
+	return b.String()
+}
+
+// DiffReport summarises the differences between a source and its baseline.
+type DiffReport struct {
+	Added     int
+	Removed   int
+	Modified  int
+	Unchanged int
+	// Invalid is the number of ports of the source failing validation. They are not compared,
+	// since an import would not write them, nor reported as removed.
+	Invalid int
+	// Passes is the number of times the source was read, once per partition.
+	Passes int
+	// Elapsed is the duration of the diff.
+	Elapsed time.Duration
+}
+
+// String formats the report for display.
+func (r *DiffReport) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Ports compared in %s (%d passes)\n", r.Elapsed.Round(time.Millisecond), r.Passes)
+	fmt.Fprintf(&b, "  added:     %d\n", r.Added)
+	fmt.Fprintf(&b, "  removed:   %d\n", r.Removed)
+	fmt.Fprintf(&b, "  modified:  %d\n", r.Modified)
+	fmt.Fprintf(&b, "  unchanged: %d\n", r.Unchanged)
+	fmt.Fprintf(&b, "  invalid:   %d\n", r.Invalid)
+	return b.String()
+}
+
+// SourceOpener opens a source, which a diff reads once per partition.
+// The reader of the source is closed after each read when it is an io.Closer.
+type SourceOpener func() (Source, error)
+
+// DiffRepository compares the ports of the source to the ones stored in the repository, as if the
+// source was imported with WithFullSync, calling fn with every change in UNLOC order within a partition.
+// Memory stays bounded however large the source is, as it is read a partition of UNLOCs at a time.
+func (s *PortService) DiffRepository(ctx context.Context, open SourceOpener, fn func(PortChange) error) (DiffReport, error) {
+	return s.diff(ctx, open, &repositoryBaseline{repo: s.repo, batchSize: s.batchSize}, fn)
+}
+
+// DiffSources compares the ports of the source to the ones of the baseline source, as DiffRepository does.
+// Both sources are read once per partition.
+func (s *PortService) DiffSources(ctx context.Context, baseline, open SourceOpener, fn func(PortChange) error) (DiffReport, error) {
+	return s.diff(ctx, open, &sourceBaseline{open: baseline, size: s.diffPartitionSize}, fn)
+}
+
+// diffBaseline is what a diff compares the partitions of a source to.
+type diffBaseline interface {
+	// compare compares a partition of the source to the baseline, or returns errRepartition
+	// when the baseline does not fit in a single partition.
+	compare(ctx context.Context, d *portDiff, ports partition) error
+	// finish reports the ports of the baseline missing from the source, once all partitions were compared.
+	finish(ctx context.Context, d *portDiff) error
+}
+
+// partition holds the ports of a source whose UNLOC belongs to the same partition, by UNLOC.
+// Ports only found invalid are held as nil, so that they are not reported as removed.
+type partition struct {
+	index, count int
+	ports        map[domain.UNLOCODE]*domain.Port
+	// records is the number of records read, in the whole source when it overflowed.
+	records int
+	// overflow is set when the source holds more ports than a partition does, which are then not all held.
+	overflow bool
+}
+
+// errRepartition is returned by the first partition of a diff when the ports do not fit in it.
+type errRepartition struct {
+	records int
+}
+
+func (e *errRepartition) Error() string {
+	return fmt.Sprintf("%d records do not fit in a single partition", e.records)
+}
+
+// portDiff is the state of a diff in progress.
+type portDiff struct {
+	fn     func(PortChange) error
+	report DiffReport
+	// seen holds the UNLOCs found in the source, invalid ports included.
+	seen keySet
+}
+
+func (s *PortService) diff(ctx context.Context, open SourceOpener, baseline diffBaseline, fn func(PortChange) error) (DiffReport, error) {
+	start := time.Now()
+	d := &portDiff{fn: fn}
+
+	partitions := 1
+	for index := 0; index < partitions; index++ {
+		ports, invalid, err := readPartition(ctx, open, index, partitions, s.diffPartitionSize, d.seen.add)
+		if err != nil {
+			return d.finish(start), err
+		}
+		d.report.Passes++
+
+		if !ports.overflow {
+			err = baseline.compare(ctx, d, ports)
+		}
+		var repartition *errRepartition
+		if partitions == 1 && (ports.overflow || errors.As(err, &repartition)) {
+			// Nothing was reported yet, so the diff starts over with enough partitions
+			records := ports.records
+			if repartition != nil && repartition.records > records {
+				records = repartition.records
+			}
+			// Hashing does not split UNLOCs evenly, so partitions are given some slack
+			partitions = records*5/4/s.diffPartitionSize + 1
+			index = -1
+			continue
+		}
+		if err != nil {
+			return d.finish(start), err
+		}
+		d.report.Invalid += invalid
+	}
+
+	err := baseline.finish(ctx, d)
+	return d.finish(start), err
+}
+
+// change reports a change.
+func (d *portDiff) change(change PortChange) error {
+	switch change.Kind {
+	case PortAdded:
+		d.report.Added++
+	case PortRemoved:
+		d.report.Removed++
+	case PortModified:
+		d.report.Modified++
+	}
+	return d.fn(change)
+}
+
+// compare reports the change between two versions of a port, either of them being nil when missing.
+func (d *portDiff) compare(unloc domain.UNLOCODE, old, new *domain.Port) error {
+	switch {
+	case old == nil:
+		return d.change(PortChange{UNLOC: unloc, Kind: PortAdded})
+	case new == nil:
+		return d.change(PortChange{UNLOC: unloc, Kind: PortRemoved})
+	}
+
+	fields := domain.CompareFields(*old, *new)
+	if len(fields) == 0 {
+		d.report.Unchanged++
+		return nil
+	}
+	return d.change(PortChange{UNLOC: unloc, Kind: PortModified, Fields: fields})
+}
+
+func (d *portDiff) finish(start time.Time) DiffReport {
+	d.report.Elapsed = time.Since(start)
+	return d.report
+}
+
+// readPartition reads the ports of the source belonging to the given partition, and returns them
+// along with the number of invalid ones. As an import would, it keeps the last valid occurrence of
+// each port. When there is a single partition, it stops holding ports past the given size.
+// Seen is called with the UNLOC of every port of the partition.
+func readPartition(ctx context.Context, open SourceOpener, index, partitions, size int, seen func(unloc domain.UNLOCODE) bool) (partition, int, error) {
+	ports := partition{index: index, count: partitions, ports: make(map[domain.UNLOCODE]*domain.Port)}
+	invalid := 0
+
+	err := readSource(ctx, open, func(rec format.Record) {
+		ports.records++
+		unloc := rec.Port.UNLOC
+		if partitions > 1 && shardFor(unloc, partitions) != index {
+			return
+		}
+		if seen != nil {
+			seen(unloc)
+		}
+		if ports.overflow {
+			return
+		}
+
+		if err := validatePort(&rec.Port); err != nil {
+			invalid++
+			if _, exists := ports.ports[unloc]; !exists {
+				ports.ports[unloc] = nil
+			}
+		} else {
+			port := rec.Port
+			ports.ports[unloc] = &port
+		}
+
+		if partitions == 1 && len(ports.ports) > size {
+			ports.overflow = true
+			ports.ports = nil
+		}
+	})
+	return ports, invalid, err
+}
+
+// readSource opens the source and calls fn with each of its records.
+func readSource(ctx context.Context, open SourceOpener, fn func(rec format.Record)) error {
+	src, err := open()
+	if err != nil {
+		return err
+	}
+	if closer, ok := src.Reader.(io.Closer); ok {
+		defer closer.Close()
+	}
+
+	dec, err := format.NewDecoder(src.Format, src.Reader)
+	if err != nil {
+		return err
+	}
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		rec, err := dec.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		fn(rec)
+	}
+}
+
+// sortedUNLOCs returns the UNLOCs of the ports in order.
+func sortedUNLOCs(ports map[domain.UNLOCODE]*domain.Port) []domain.UNLOCODE {
+	unlocs := make([]domain.UNLOCODE, 0, len(ports))
+	for unloc := range ports {
+		unlocs = append(unlocs, unloc)
+	}
+	sortUNLOCs(unlocs)
+	return unlocs
+}
+
+// repositoryBaseline compares sources to the ports stored in a repository.
+type repositoryBaseline struct {
+	repo      PortRepository
+	batchSize int
+}
+
+func (b *repositoryBaseline) compare(ctx context.Context, d *portDiff, ports partition) error {
+	unlocs := sortedUNLOCs(ports.ports)
+	for len(unlocs) > 0 {
+		batch := unlocs
+		if len(batch) > b.batchSize {
+			batch = batch[:b.batchSize]
+		}
+		unlocs = unlocs[len(batch):]
+
+		// Ports whose stored hash did not change are not read at all
+		var hashes []string
+		if repo, ok := b.repo.(PortHashRepository); ok {
+			var err error
+			if hashes, err = repo.GetPortHashes(ctx, batch); err != nil {
+				return err
+			}
+		}
+
+		for i, unloc := range batch {
+			port := ports.ports[unloc]
+			if port == nil {
+				continue
+			}
+			if hashes != nil && hashes[i] == port.Hash() {
+				d.report.Unchanged++
+				continue
+			}
+
+			stored, err := b.repo.GetPortByUNLOC(ctx, unloc)
+			if err != nil {
+				return err
+			}
+			if err := d.compare(unloc, stored, port); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (b *repositoryBaseline) finish(ctx context.Context, d *portDiff) error {
+	return b.repo.ScanUNLOCs(ctx, func(unlocs []domain.UNLOCODE) error {
+		sortUNLOCs(unlocs)
+		for _, unloc := range unlocs {
+			if d.seen.contains(unloc) {
+				continue
+			}
+			// A scan may report a port more than once, it is only reported the first time
+			d.seen.add(unloc)
+			if err := d.change(PortChange{UNLOC: unloc, Kind: PortRemoved}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// sourceBaseline compares sources to the ports of another source.
+type sourceBaseline struct {
+	open SourceOpener
+	size int
+}
+
+func (b *sourceBaseline) compare(ctx context.Context, d *portDiff, ports partition) error {
+	baseline, _, err := readPartition(ctx, b.open, ports.index, ports.count, b.size, nil)
+	if err != nil {
+		return err
+	}
+	if baseline.overflow {
+		return &errRepartition{records: baseline.records}
+	}
+
+	// The ports only found in the source are added to the baseline ones, so that all are compared in order
+	unlocs := baseline.ports
+	for unloc := range ports.ports {
+		if _, exists := unlocs[unloc]; !exists {
+			unlocs[unloc] = nil
+		}
+	}
+
+	for _, unloc := range sortedUNLOCs(unlocs) {
+		old := baseline.ports[unloc]
+		new, inSource := ports.ports[unloc]
+		switch {
+		case inSource && new == nil:
+			// The port is only found invalid in the source, so an import would not touch it
+			continue
+		case old == nil && new == nil:
+			// The port is only found invalid in the baseline, and missing from the source
+			continue
+		}
+		if err := d.compare(unloc, old, new); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (b *sourceBaseline) finish(context.Context, *portDiff) error {
+	return nil
+}
+
+// sortUNLOCs sorts the UNLOCs in increasing order.
+func sortUNLOCs(unlocs []domain.UNLOCODE) {
+	sort.Slice(unlocs, func(i, j int) bool {
+		return unlocs[i] < unlocs[j]
+	})
+}
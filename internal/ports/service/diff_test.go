@@ -0,0 +1,133 @@
+package service_test
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"ports-service/internal/ports/domain"
+	"ports-service/internal/ports/service"
+)
+
+func TestPortService_DiffRepository(t *testing.T) {
+	ctx := context.Background()
+
+	stored := map[domain.UNLOCODE]domain.Port{
+		"AEJEA": {UNLOC: "AEJEA", Name: "Jebel Ali", City: "Jebel Ali", Country: "United Arab Emirates", CountryCode: "AE", Province: "Dubai"},
+		"AEJED": {UNLOC: "AEJED", Name: "Jebel Dhanna", City: "Jebel Dhanna", Country: "United Arab Emirates", CountryCode: "AE"},
+		"AEAJM": {UNLOC: "AEAJM", Name: "Ajman", City: "Ajman", Country: "United Arab Emirates", CountryCode: "AE"},
+	}
+	input := `{
+		"AEJEA": {"name": "Jebel Ali", "city": "Jebel Ali", "country": "United Arab Emirates", "province": "Dubai"},
+		"AEJED": {"name": "Jebel Dhanna Port", "city": "Jebel Dhanna", "country": "United Arab Emirates"},
+		"AEDXB": {"name": "Dubai", "city": "Dubai", "country": "United Arab Emirates"},
+		"AEJEB": {"name": "Invalid Port"}
+	}`
+
+	repositories := map[string]func() service.PortRepository{
+		"repository": func() service.PortRepository {
+			return &mockPortRepository{ports: copyPorts(stored)}
+		},
+		"repository with hashes": func() service.PortRepository {
+			return &mockHashPortRepository{
+				mockBatchPortRepository: mockBatchPortRepository{
+					mockPortRepository: mockPortRepository{ports: copyPorts(stored)},
+				},
+			}
+		},
+	}
+
+	for name, newRepo := range repositories {
+		t.Run(name, func(t *testing.T) {
+			portService := service.NewPortService(newRepo())
+
+			var changes []service.PortChange
+			report, err := portService.DiffRepository(ctx, stringSource(input), func(change service.PortChange) error {
+				changes = append(changes, change)
+				return nil
+			})
+			assert.NoError(t, err)
+			assert.Equal(t, []service.PortChange{
+				{UNLOC: "AEDXB", Kind: service.PortAdded},
+				{UNLOC: "AEJED", Kind: service.PortModified, Fields: []domain.FieldChange{
+					{Field: "name", Old: "Jebel Dhanna", New: "Jebel Dhanna Port"},
+				}},
+				{UNLOC: "AEAJM", Kind: service.PortRemoved},
+			}, changes)
+			assert.Equal(t, 1, report.Added)
+			assert.Equal(t, 1, report.Modified)
+			assert.Equal(t, 1, report.Removed)
+			assert.Equal(t, 1, report.Unchanged)
+			assert.Equal(t, 1, report.Invalid)
+			assert.Equal(t, 1, report.Passes)
+		})
+	}
+}
+
+func TestPortService_DiffSources(t *testing.T) {
+	ctx := context.Background()
+
+	baseline := generatePorts(50)
+	// Rename port 1, remove port 2 and add port 50
+	input := strings.Replace(generatePorts(51), `"name": "Port 1"`, `"name": "Port 1 renamed"`, 1)
+	input = strings.Replace(input, fmt.Sprintf(`"%s": {"name": "Port 2", "city": "City 2", "country": "%s"},`, syntheticUNLOC(2), syntheticUNLOC(2)[:2]), "", 1)
+
+	tests := []struct {
+		name          string
+		partitionSize int
+		baseline      string
+		input         string
+		added         int
+		removed       int
+		passes        int
+	}{
+		{name: "single partition", partitionSize: 100, baseline: baseline, input: input, added: 1, removed: 1, passes: 1},
+		{name: "partitioned source", partitionSize: 10, baseline: baseline, input: input, added: 1, removed: 1, passes: 8},
+		// Port 50 is not added and ports 51 to 69 are removed as well
+		{name: "partitioned baseline", partitionSize: 60, baseline: generatePorts(70), input: input, removed: 20, passes: 3},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			portService := service.NewPortService(&mockPortRepository{}, service.WithDiffPartitionSize(test.partitionSize))
+
+			changes := make(map[domain.UNLOCODE]service.PortChange)
+			report, err := portService.DiffSources(ctx, stringSource(test.baseline), stringSource(test.input), func(change service.PortChange) error {
+				changes[change.UNLOC] = change
+				return nil
+			})
+			assert.NoError(t, err)
+			assert.Equal(t, test.added, report.Added)
+			assert.Equal(t, 1, report.Modified)
+			assert.Equal(t, test.removed, report.Removed)
+			assert.Equal(t, test.passes, report.Passes)
+			assert.Equal(t, service.PortRemoved, changes[domain.UNLOCODE(syntheticUNLOC(2))].Kind)
+			assert.Equal(t, []domain.FieldChange{{Field: "name", Old: "Port 1", New: "Port 1 renamed"}}, changes[domain.UNLOCODE(syntheticUNLOC(1))].Fields)
+		})
+	}
+}
+
+func TestPortChange_String(t *testing.T) {
+	change := service.PortChange{UNLOC: "AEJED", Kind: service.PortModified, Fields: []domain.FieldChange{
+		{Field: "name", Old: "Jebel Dhanna", New: "Jebel Dhanna Port"},
+	}}
+	assert.Equal(t, "~ AEJED\n    name: \"Jebel Dhanna\" -> \"Jebel Dhanna Port\"\n", change.String())
+}
+
+// stringSource returns a SourceOpener of the given input.
+func stringSource(input string) service.SourceOpener {
+	return func() (service.Source, error) {
+		return service.Source{Reader: strings.NewReader(input)}, nil
+	}
+}
+
+func copyPorts(ports map[domain.UNLOCODE]domain.Port) map[domain.UNLOCODE]domain.Port {
+	copied := make(map[domain.UNLOCODE]domain.Port, len(ports))
+	for unloc, port := range ports {
+		copied[unloc] = port
+	}
+	return copied
+}
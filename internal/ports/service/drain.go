@@ -0,0 +1,54 @@
+package service
+
+import (
+	"context"
+	"time"
+)
+
+// defaultDrainTimeout is the time given to the ports in flight to be written once an import is canceled.
+const defaultDrainTimeout = 5 * time.Second
+
+// drain returns the context of the writes of an import. Canceling the import context only stops
+// decoding: the writes go on with the ports already decoded until the timeout elapses, after which
+// their context is canceled as well. The returned function must be called once the import is over.
+func drain(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	writeCtx, cancel := context.WithCancel(detachedContext{ctx})
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-done:
+			return
+		}
+
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+			cancel()
+		case <-done:
+		}
+	}()
+
+	return writeCtx, func() {
+		close(done)
+		cancel()
+	}
+}
+
+// detachedContext holds the values of its parent without being canceled along with it.
+type detachedContext struct {
+	context.Context
+}
+
+func (detachedContext) Deadline() (time.Time, bool) {
+	return time.Time{}, false
+}
+
+func (detachedContext) Done() <-chan struct{} {
+	return nil
+}
+
+func (detachedContext) Err() error {
+	return nil
+}
@@ -0,0 +1,94 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+
+	"ports-service/internal/ports/domain"
+)
+
+// DuplicatePolicy decides what an import does with the ports repeating an UNLOC found earlier in its source.
+type DuplicatePolicy string
+
+const (
+	// LastWins writes every occurrence in order, so the last one wins. It is the default.
+	LastWins DuplicatePolicy = "last-wins"
+	// FirstWins skips the occurrences following the first one.
+	FirstWins DuplicatePolicy = "first-wins"
+	// MergeDuplicates merges the fields set by the later occurrences into the stored port,
+	// see domain.Port Merge. Later occurrences are validated once merged. Dry runs merge them
+	// into the earlier occurrences instead, as far as they can keep them within the memory budget.
+	MergeDuplicates DuplicatePolicy = "merge"
+	// RejectDuplicates rejects the occurrences following the first one, which is still imported.
+	RejectDuplicates DuplicatePolicy = "reject"
+)
+
+// ErrDuplicateKey is the error of the ports rejected by RejectDuplicates.
+var ErrDuplicateKey = errors.New("duplicate UNLOC")
+
+// DuplicateKey is an UNLOC found several times in a source.
+type DuplicateKey struct {
+	UNLOC domain.UNLOCODE
+	// Offsets holds the byte offsets of the occurrences of the UNLOC in the source, in order. The first
+	// occurrence is missing when it does not belong to the first maxIndexedOffsets ports of the source.
+	Offsets []int64
+}
+
+// duplicateKeys tracks the UNLOCs found in a source, and the duplicates among them.
+// It takes less than 8MB for well-formed UNLOCs, plus up to 12MB to find the offset of the first
+// occurrence of the duplicates, so that the source is never held in memory.
+type duplicateKeys struct {
+	seen  keySet
+	first offsetIndex
+	count int
+	// sample holds the first maxReportedFailures duplicate keys, without their first offset until finish.
+	sample map[domain.UNLOCODE]*DuplicateKey
+	order  []domain.UNLOCODE
+}
+
+// add records the UNLOC found at the given offset, and reports whether it was found before.
+func (d *duplicateKeys) add(unloc domain.UNLOCODE, offset int64) bool {
+	if !d.seen.add(unloc) {
+		d.first.add(unloc, offset)
+		return false
+	}
+
+	d.count++
+	if key, ok := d.sample[unloc]; ok {
+		key.Offsets = append(key.Offsets, offset)
+	} else if len(d.order) < maxReportedFailures {
+		if d.sample == nil {
+			d.sample = make(map[domain.UNLOCODE]*DuplicateKey)
+		}
+		d.sample[unloc] = &DuplicateKey{UNLOC: unloc, Offsets: []int64{offset}}
+		d.order = append(d.order, unloc)
+	}
+	return true
+}
+
+// keys returns the sampled duplicate keys, in the order they were found.
+func (d *duplicateKeys) keys() []DuplicateKey {
+	if len(d.order) == 0 {
+		return nil
+	}
+
+	d.first.lookup(d.order, func(unloc domain.UNLOCODE, offset int64) {
+		key := d.sample[unloc]
+		key.Offsets = append([]int64{offset}, key.Offsets...)
+	})
+	keys := make([]DuplicateKey, len(d.order))
+	for i, unloc := range d.order {
+		keys[i] = *d.sample[unloc]
+	}
+	return keys
+}
+
+// validate returns an error for unknown policies.
+func (p DuplicatePolicy) validate() error {
+	switch p {
+	case LastWins, FirstWins, MergeDuplicates, RejectDuplicates:
+		return nil
+	default:
+		return fmt.Errorf("unknown duplicate policy %q", p)
+	}
+}
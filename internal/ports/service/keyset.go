@@ -0,0 +1,157 @@
+package service
+
+import (
+	"math/bits"
+
+	"ports-service/internal/ports/domain"
+)
+
+// unlocAlphabet holds the characters of well-formed UNLOCs, which keySet packs into bits.
+const unlocAlphabet = "ABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+
+// unlocSpace is the number of well-formed UNLOCs, five characters of unlocAlphabet.
+const unlocSpace = 36 * 36 * 36 * 36 * 36
+
+// keySet is a set of UNLOCs. Well-formed UNLOCs are stored as bits, so that the UNLOCs of
+// millions of ports take less than 8MB, while the others fall back to a map.
+// The zero value is an empty set, it is not safe for concurrent use.
+type keySet struct {
+	bits   []uint64
+	others map[domain.UNLOCODE]struct{}
+	size   int
+}
+
+// add adds the UNLOC to the set and reports whether it was already in it.
+func (k *keySet) add(unloc domain.UNLOCODE) bool {
+	i, ok := unlocIndex(unloc)
+	if !ok {
+		if k.others == nil {
+			k.others = make(map[domain.UNLOCODE]struct{})
+		}
+		if _, exists := k.others[unloc]; exists {
+			return true
+		}
+		k.others[unloc] = struct{}{}
+		k.size++
+		return false
+	}
+
+	if k.bits == nil {
+		k.bits = make([]uint64, (unlocSpace+63)/64)
+	}
+	word, bit := i/64, uint64(1)<<(i%64)
+	if k.bits[word]&bit != 0 {
+		return true
+	}
+	k.bits[word] |= bit
+	k.size++
+	return false
+}
+
+// contains reports whether the UNLOC is in the set.
+func (k *keySet) contains(unloc domain.UNLOCODE) bool {
+	i, ok := unlocIndex(unloc)
+	if !ok {
+		_, exists := k.others[unloc]
+		return exists
+	}
+	return k.bits != nil && k.bits[i/64]&(uint64(1)<<(i%64)) != 0
+}
+
+// union adds the UNLOCs of the other set to the set.
+func (k *keySet) union(other *keySet) {
+	for unloc := range other.others {
+		k.add(unloc)
+	}
+	if other.bits == nil {
+		return
+	}
+
+	if k.bits == nil {
+		k.bits = make([]uint64, len(other.bits))
+	}
+	for i, word := range other.bits {
+		k.size += bits.OnesCount64(word &^ k.bits[i])
+		k.bits[i] |= word
+	}
+}
+
+// len returns the number of UNLOCs in the set.
+func (k *keySet) len() int {
+	return k.size
+}
+
+// unlocIndex returns the index of a well-formed UNLOC among all of them.
+func unlocIndex(unloc domain.UNLOCODE) (int, bool) {
+	if len(unloc) != 5 {
+		return 0, false
+	}
+
+	index := 0
+	for i := 0; i < len(unloc); i++ {
+		c := unloc[i]
+		var digit int
+		switch {
+		case c >= 'A' && c <= 'Z':
+			digit = int(c - 'A')
+		case c >= '0' && c <= '9':
+			digit = 26 + int(c-'0')
+		default:
+			return 0, false
+		}
+		index = index*len(unlocAlphabet) + digit
+	}
+	return index, true
+}
+
+// maxIndexedOffsets is the number of UNLOCs whose first offset is remembered by an offsetIndex,
+// which then takes up to 12MB.
+const maxIndexedOffsets = 1 << 20
+
+// offsetIndex remembers the offset at which UNLOCs were first found, taking 12 bytes per
+// well-formed UNLOC, and looks them up in a single pass once the whole input was read.
+// Only the first maxIndexedOffsets UNLOCs are remembered, so that it does not grow with the input.
+// The zero value is an empty index, it is not safe for concurrent use.
+type offsetIndex struct {
+	keys    []uint32
+	offsets []int64
+	others  map[domain.UNLOCODE]int64
+}
+
+// add records the offset at which the UNLOC was first found, unless the index is full.
+func (x *offsetIndex) add(unloc domain.UNLOCODE, offset int64) {
+	if len(x.keys)+len(x.others) >= maxIndexedOffsets {
+		return
+	}
+	i, ok := unlocIndex(unloc)
+	if !ok {
+		if x.others == nil {
+			x.others = make(map[domain.UNLOCODE]int64)
+		}
+		x.others[unloc] = offset
+		return
+	}
+	x.keys = append(x.keys, uint32(i))
+	x.offsets = append(x.offsets, offset)
+}
+
+// lookup calls fn with the offset at which each of the given UNLOCs was first found.
+func (x *offsetIndex) lookup(unlocs []domain.UNLOCODE, fn func(unloc domain.UNLOCODE, offset int64)) {
+	wanted := make(map[uint32]domain.UNLOCODE, len(unlocs))
+	for _, unloc := range unlocs {
+		if i, ok := unlocIndex(unloc); ok {
+			wanted[uint32(i)] = unloc
+		} else if offset, exists := x.others[unloc]; exists {
+			fn(unloc, offset)
+		}
+	}
+	if len(wanted) == 0 {
+		return
+	}
+
+	for i, key := range x.keys {
+		if unloc, ok := wanted[key]; ok {
+			fn(unloc, x.offsets[i])
+		}
+	}
+}
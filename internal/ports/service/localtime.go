@@ -0,0 +1,89 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"ports-service/internal/ports/domain"
+)
+
+// ErrPortNotFound is returned when asking about ports that are not stored.
+var ErrPortNotFound = errors.New("port not found")
+
+// transitionsHorizon is how far ahead LocalTime looks for the UTC offset transitions of a port.
+const transitionsHorizon = 366 * 24 * time.Hour
+
+// PortTime is the local time at a port.
+type PortTime struct {
+	UNLOC    domain.UNLOCODE `json:"unloc"`
+	Timezone string          `json:"timezone"`
+	// LocalTime is the instant asked about, as seen at the port.
+	LocalTime time.Time `json:"local_time"`
+	// Zone is the abbreviation of the zone in effect, e.g. "GST".
+	Zone string `json:"zone"`
+	// UTCOffsetSeconds is the offset from UTC in effect, in seconds east of UTC.
+	UTCOffsetSeconds int  `json:"utc_offset_seconds"`
+	DST              bool `json:"dst"`
+	// Transitions are the changes of UTC offset at the port over the following year.
+	Transitions []domain.Transition `json:"transitions,omitempty"`
+}
+
+// LocalTime returns the local time at the port stored with the given UNLOC at the instant t, or nil when
+// the port is not stored. Ports without a timezone fail with domain.ErrNoTimezone.
+func (s *PortService) LocalTime(ctx context.Context, unloc domain.UNLOCODE, t time.Time) (*PortTime, error) {
+	port, err := s.repo.GetPortByUNLOC(ctx, unloc)
+	if err != nil || port == nil {
+		return nil, err
+	}
+
+	local, err := port.LocalTime(t)
+	if err != nil {
+		return nil, err
+	}
+	transitions, err := port.Transitions(t, t.Add(transitionsHorizon))
+	if err != nil {
+		return nil, err
+	}
+	zone, offset := local.Zone()
+	return &PortTime{
+		UNLOC:            port.UNLOC,
+		Timezone:         port.Timezone,
+		LocalTime:        local,
+		Zone:             zone,
+		UTCOffsetSeconds: offset,
+		DST:              local.IsDST(),
+		Transitions:      transitions,
+	}, nil
+}
+
+// BusinessHoursStatus tells whether a port is within its business hours.
+type BusinessHoursStatus struct {
+	UNLOC     domain.UNLOCODE `json:"unloc"`
+	LocalTime time.Time       `json:"local_time"`
+	Open      bool            `json:"open"`
+}
+
+// BusinessHours reports whether the instant t falls within the given business hours at each of the ports
+// stored with the given UNLOCs, in the same order. It fails with ErrPortNotFound when a port is not stored,
+// and with domain.ErrNoTimezone when a port has no timezone.
+func (s *PortService) BusinessHours(ctx context.Context, unlocs []domain.UNLOCODE, hours domain.BusinessHours, t time.Time) ([]BusinessHoursStatus, error) {
+	statuses := make([]BusinessHoursStatus, 0, len(unlocs))
+	for _, unloc := range unlocs {
+		port, err := s.repo.GetPortByUNLOC(ctx, unloc)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get port %s: %w", unloc, err)
+		}
+		if port == nil {
+			return nil, fmt.Errorf("%w: %s", ErrPortNotFound, unloc)
+		}
+
+		local, err := port.LocalTime(t)
+		if err != nil {
+			return nil, err
+		}
+		statuses = append(statuses, BusinessHoursStatus{UNLOC: unloc, LocalTime: local, Open: hours.Contains(local)})
+	}
+	return statuses, nil
+}
@@ -0,0 +1,66 @@
+package service_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"ports-service/internal/ports/domain"
+	"ports-service/internal/ports/service"
+)
+
+func TestPortService_LocalTime(t *testing.T) {
+	ctx := context.Background()
+	repo := &mockPortRepository{ports: map[domain.UNLOCODE]domain.Port{
+		"NLRTM": {UNLOC: "NLRTM", Name: "Rotterdam", City: "Rotterdam", Country: "Netherlands", Timezone: "Europe/Amsterdam"},
+		"AEJEA": {UNLOC: "AEJEA", Name: "Jebel Ali", City: "Dubai", Country: "United Arab Emirates"},
+	}}
+	portService := service.NewPortService(repo)
+	instant := time.Date(2024, time.July, 1, 12, 0, 0, 0, time.UTC)
+
+	portTime, err := portService.LocalTime(ctx, "NLRTM", instant)
+	assert.NoError(t, err)
+	assert.Equal(t, 14, portTime.LocalTime.Hour())
+	assert.Equal(t, "CEST", portTime.Zone)
+	assert.Equal(t, 7200, portTime.UTCOffsetSeconds)
+	assert.True(t, portTime.DST)
+	// Summer time ends in October and starts again in March
+	assert.Len(t, portTime.Transitions, 2)
+	assert.False(t, portTime.Transitions[0].DST)
+
+	_, err = portService.LocalTime(ctx, "AEJEA", instant)
+	assert.ErrorIs(t, err, domain.ErrNoTimezone)
+
+	portTime, err = portService.LocalTime(ctx, "AEDXB", instant)
+	assert.NoError(t, err)
+	assert.Nil(t, portTime)
+}
+
+func TestPortService_BusinessHours(t *testing.T) {
+	ctx := context.Background()
+	repo := &mockPortRepository{ports: map[domain.UNLOCODE]domain.Port{
+		"NLRTM": {UNLOC: "NLRTM", Name: "Rotterdam", City: "Rotterdam", Country: "Netherlands", Timezone: "Europe/Amsterdam"},
+		"CNSHA": {UNLOC: "CNSHA", Name: "Shanghai", City: "Shanghai", Country: "China", Timezone: "Asia/Shanghai"},
+	}}
+	portService := service.NewPortService(repo)
+	// Wednesday 10:00 in Rotterdam, 16:00 in Shanghai
+	instant := time.Date(2024, time.July, 3, 8, 0, 0, 0, time.UTC)
+
+	statuses, err := portService.BusinessHours(ctx, []domain.UNLOCODE{"NLRTM", "CNSHA"}, domain.DefaultBusinessHours, instant)
+	assert.NoError(t, err)
+	assert.Len(t, statuses, 2)
+	assert.Equal(t, domain.UNLOCODE("NLRTM"), statuses[0].UNLOC)
+	assert.True(t, statuses[0].Open)
+	assert.Equal(t, 16, statuses[1].LocalTime.Hour())
+	assert.True(t, statuses[1].Open)
+
+	statuses, err = portService.BusinessHours(ctx, []domain.UNLOCODE{"NLRTM", "CNSHA"}, domain.DefaultBusinessHours, instant.Add(5*time.Hour))
+	assert.NoError(t, err)
+	assert.True(t, statuses[0].Open)
+	assert.False(t, statuses[1].Open)
+
+	_, err = portService.BusinessHours(ctx, []domain.UNLOCODE{"NLRTM", "AEDXB"}, domain.DefaultBusinessHours, instant)
+	assert.ErrorIs(t, err, service.ErrPortNotFound)
+}
@@ -0,0 +1,97 @@
+package service_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"ports-service/internal/ports/domain"
+	"ports-service/internal/ports/service"
+)
+
+// racingPortRepository is a ConditionalPortRepository where another importer adds an alias to
+// each port between the first time it is read and written.
+type racingPortRepository struct {
+	mockPortRepository
+	raced    map[domain.UNLOCODE]bool
+	attempts int
+}
+
+func (m *racingPortRepository) UpsertPortIf(ctx context.Context, port domain.Port, previous *domain.Port) (domain.UpsertStatus, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.attempts++
+	stored, exists := m.ports[port.UNLOC]
+	if !m.raced[port.UNLOC] && exists {
+		m.raced[port.UNLOC] = true
+		stored.Alias = append(stored.Alias, "Concurrent Alias")
+		m.ports[port.UNLOC] = stored
+	}
+	if exists != (previous != nil) || exists && stored.Hash() != previous.Hash() {
+		return 0, domain.ErrPortChanged
+	}
+
+	m.ports[port.UNLOC] = port
+	if exists {
+		return domain.Updated, nil
+	}
+	return domain.Inserted, nil
+}
+
+func TestPortService_LoadPorts_MergePolicy(t *testing.T) {
+	ctx := context.Background()
+
+	stored := domain.Port{
+		UNLOC: "AEJEA", Name: "Jebel Ali Port", City: "Jebel Ali", Country: "United Arab Emirates",
+		Alias: []string{"Mina Jebel Ali"}, Province: "Dubai Emirate",
+	}
+	repo := &racingPortRepository{
+		mockPortRepository: mockPortRepository{ports: map[domain.UNLOCODE]domain.Port{stored.UNLOC: stored}},
+		raced:              make(map[domain.UNLOCODE]bool),
+	}
+	policy, err := domain.ParseMergePolicy("keep-existing,alias=union")
+	assert.NoError(t, err)
+	portService := service.NewPortService(repo, service.WithMergePolicy(policy))
+
+	report, err := portService.LoadPorts(ctx, strings.NewReader(samplePorts))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, report.Inserted)
+	assert.Equal(t, 1, report.Updated)
+	// The merge of the stored port was retried once it changed
+	assert.Equal(t, 3, repo.attempts)
+
+	// The stored fields are kept, the empty ones are filled in and the alias added concurrently is kept
+	merged := repo.ports[stored.UNLOC]
+	assert.Equal(t, "Jebel Ali Port", merged.Name)
+	assert.Equal(t, "Dubai Emirate", merged.Province)
+	assert.Equal(t, "Asia/Dubai", merged.Timezone)
+	assert.Equal(t, []string{"Mina Jebel Ali", "Concurrent Alias"}, merged.Alias)
+
+	_, err = service.NewPortService(repo, service.WithMergePolicy(domain.MergePolicy{Default: "random"})).LoadPorts(ctx, strings.NewReader(samplePorts))
+	assert.Error(t, err)
+}
+
+func TestPortService_LoadPorts_MergePolicyValidatesMergedPorts(t *testing.T) {
+	ctx := context.Background()
+
+	// Stored before the country was validated, the kept country does not match the UNLOC
+	stored := domain.Port{UNLOC: "AEJEA", Name: "Jebel Ali", City: "Jebel Ali", Country: "Oman"}
+	repo := &mockPortRepository{ports: map[domain.UNLOCODE]domain.Port{stored.UNLOC: stored}}
+	policy, err := domain.ParseMergePolicy("replace,country=keep-existing")
+	assert.NoError(t, err)
+	portService := service.NewPortService(repo, service.WithMergePolicy(policy))
+
+	report, err := portService.LoadPorts(ctx, strings.NewReader(samplePorts))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, report.Inserted)
+	assert.Equal(t, 0, report.Updated)
+	assert.Equal(t, 1, report.Invalid)
+	if assert.Len(t, report.Failures, 1) {
+		assert.Equal(t, stored.UNLOC, report.Failures[0].UNLOC)
+		assert.Equal(t, service.StageValidate, report.Failures[0].Stage)
+	}
+	assert.Equal(t, stored, repo.ports[stored.UNLOC])
+}
@@ -0,0 +1,411 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"sync/atomic"
+
+	"ports-service/internal/ports/domain"
+	"ports-service/internal/ports/format"
+)
+
+// stageBufferSize is the capacity of the channels connecting the pipeline stages.
+// It bounds the number of ports held in memory per worker.
+const stageBufferSize = 64
+
+// maxMergeAttempts is the number of times a port is merged into the stored one when the latter keeps
+// changing before the merge is written, see ConditionalPortRepository.
+const maxMergeAttempts = 5
+
+// defaultBatchSize is the number of ports written at once to a BatchPortRepository.
+const defaultBatchSize = 100
+
+// record is a decoded port along with its position in the input.
+type record struct {
+	// seq numbers the records sent through the pipeline in input order.
+	seq int64
+	// offset is the byte offset of the record in the input.
+	offset int64
+	port   domain.Port
+	// raw is the record as found in the input, when it is JSON.
+	raw json.RawMessage
+	// duplicate is set when the UNLOC of the port was found earlier in the input.
+	duplicate bool
+	// unmerged is set when a dry run could not merge the duplicate into its earlier occurrence.
+	unmerged bool
+	// size is the estimated memory taken by the record, see inFlight.
+	size int64
+}
+
+// portDecoder is the first stage of the pipeline, it streams the ports of the input
+// into the shard owning their UNLOC.
+type portDecoder struct {
+	// abort is closed when the import exceeds its ErrorPolicy.
+	abort <-chan struct{}
+	// resumeOffset is the offset before which records were committed by a previous import.
+	resumeOffset int64
+	shards       []chan record
+	// duplicates is the policy applied to the UNLOCs found several times in the input.
+	duplicates DuplicatePolicy
+	// inFlight slows decoding down when too many records are going through the pipeline.
+	inFlight *inFlight
+	// keys tracks the UNLOCs found in the input, resumed records included.
+	keys duplicateKeys
+
+	// skipped is the number of records skipped because of the resume offset.
+	skipped int
+	// canceled tells whether decoding stopped because the import was canceled.
+	canceled bool
+}
+
+// decode streams the records decoded from the input into the shards.
+// It closes all shards when the input is exhausted, the context is canceled,
+// the import is aborted or decoding fails. A record decoded is always sent.
+func (d *portDecoder) decode(ctx context.Context, input format.Decoder) error {
+	defer func() {
+		for _, shard := range d.shards {
+			close(shard)
+		}
+	}()
+
+	var seq int64
+	for {
+		select {
+		case <-ctx.Done():
+			d.canceled = true
+			return nil // The records sent are drained, LoadSource reports the cancellation
+		case <-d.abort:
+			return nil // The import failed, LoadSource reports why
+		default:
+			// Continue processing
+		}
+
+		rec, err := input.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		duplicate := d.keys.add(rec.Port.UNLOC, rec.Offset)
+		if rec.Offset < d.resumeOffset {
+			d.skipped++
+			continue
+		}
+		if duplicate && d.duplicates == FirstWins {
+			continue
+		}
+
+		size := recordSize(rec)
+		d.inFlight.acquire(size)
+		d.shards[shardFor(rec.Port.UNLOC, len(d.shards))] <- record{
+			seq:       seq,
+			offset:    rec.Offset,
+			port:      rec.Port,
+			raw:       rec.Raw,
+			duplicate: duplicate,
+			size:      size,
+		}
+		seq++
+	}
+}
+
+// validatePorts forwards the valid records to the next stage and reports the invalid ones.
+// Duplicates are rejected or left to the next stage to merge, depending on the duplicate policy.
+// Once the gate is closed, the records left are dropped so that they are not written,
+// and reported as such.
+func (s *PortService) validatePorts(in <-chan record, out chan<- record, results chan<- importResult, gate *policyGate) {
+	for rec := range in {
+		if gate.drop(rec, results) {
+			continue
+		}
+
+		switch {
+		case rec.duplicate && s.duplicates == RejectDuplicates:
+			gate.reject()
+			results <- rec.rejected(StageDuplicate, fmt.Errorf("%w %s", ErrDuplicateKey, rec.port.UNLOC))
+			continue
+		case rec.duplicate && s.duplicates == MergeDuplicates:
+			// The port is validated once merged
+			out <- rec
+			continue
+		}
+
+		if err := validatePort(&rec.port); err != nil {
+			gate.reject()
+			results <- rec.rejected(StageValidate, err)
+			continue
+		}
+		out <- rec
+	}
+}
+
+// validatePort derives the fields of the port computed on import, then validates it.
+func validatePort(port *domain.Port) error {
+	port.DeriveCountryCode()
+	return port.Validate()
+}
+
+// writePorts upserts the ports into the repository in the order they are received.
+// Ports are written in batches when the repository supports it and they replace the stored ones,
+// and not written at all by dry runs. The ports written are recorded as coming from the given origin.
+// Once the gate is closed, the ports left are dropped rather than written.
+func (s *PortService) writePorts(ctx context.Context, origin domain.Origin, in <-chan record, results chan<- importResult, gate *policyGate) {
+	if s.dryRun {
+		// Nothing is stored, so duplicates are merged into the earlier occurrences kept by the worker,
+		// which gets all the occurrences of an UNLOC. Once the ports kept reach their share of the memory
+		// budget, no other port is kept, and the duplicates of the ports left out are validated on their own.
+		kept := make(map[domain.UNLOCODE]domain.Port)
+		var keptSize int64
+		full := false
+		earlier := func(_ context.Context, unloc domain.UNLOCODE) (*domain.Port, error) {
+			if port, ok := kept[unloc]; ok {
+				return &port, nil
+			}
+			return nil, nil
+		}
+		for rec := range in {
+			_, known := kept[rec.port.UNLOC]
+			rec.unmerged = rec.duplicate && full && !known
+			rec, ok := s.mergeDuplicate(ctx, earlier, rec, results)
+			if !ok {
+				continue
+			}
+
+			switch {
+			case known:
+				kept[rec.port.UNLOC] = rec.port
+			case s.duplicates != MergeDuplicates || full:
+			case s.keptLimit() > 0 && keptSize+rec.size > s.keptLimit():
+				full = true
+			default:
+				kept[rec.port.UNLOC] = rec.port
+				keptSize += rec.size
+			}
+			results <- importResult{record: rec}
+		}
+		return
+	}
+
+	if !s.merge.ReplacesAll() {
+		for rec := range in {
+			if gate.drop(rec, results) {
+				continue
+			}
+			rec, ok := s.mergeDuplicate(ctx, s.repo.GetPortByUNLOC, rec, results)
+			if !ok {
+				continue
+			}
+			var status domain.UpsertStatus
+			var err error
+			rec.port, status, err = s.upsertMerged(ctx, rec.port)
+			var validationErr *domain.ValidationError
+			if errors.As(err, &validationErr) {
+				gate.reject()
+				results <- rec.rejected(StageValidate, err)
+				continue
+			}
+			s.sendWritten(ctx, origin, []importResult{rec.written(status, err)}, results)
+		}
+		return
+	}
+
+	if repo, ok := s.repo.(BatchPortRepository); ok {
+		s.writeBatches(ctx, origin, repo, in, results, gate)
+		return
+	}
+
+	for rec := range in {
+		if gate.drop(rec, results) {
+			continue
+		}
+		rec, ok := s.mergeDuplicate(ctx, s.repo.GetPortByUNLOC, rec, results)
+		if !ok || len(s.skipUnchanged(ctx, origin, []record{rec}, results)) == 0 {
+			continue
+		}
+		status, err := s.repo.UpsertPort(ctx, rec.port)
+		s.sendWritten(ctx, origin, []importResult{rec.written(status, err)}, results)
+	}
+}
+
+// keptLimit returns the size of the ports each worker of a dry run keeps to merge duplicates into,
+// their share of the memory budget, or zero without a budget.
+func (s *PortService) keptLimit() int64 {
+	return s.memoryBudget / keptShare / int64(s.workers)
+}
+
+// writeBatches upserts the ports into the repository in batches of up to batchSize ports.
+func (s *PortService) writeBatches(ctx context.Context, origin domain.Origin, repo BatchPortRepository, in <-chan record, results chan<- importResult, gate *policyGate) {
+	batch := make([]record, 0, s.batchSize)
+	ports := make([]domain.Port, 0, s.batchSize)
+	written := make([]importResult, 0, s.batchSize)
+	flush := func() {
+		if gate.closed() {
+			for _, rec := range batch {
+				results <- importResult{record: rec, dropped: true}
+			}
+			batch = batch[:0]
+			return
+		}
+		batch = s.skipUnchanged(ctx, origin, batch, results)
+		for _, rec := range batch {
+			ports = append(ports, rec.port)
+		}
+		if len(ports) > 0 {
+			for i, result := range repo.UpsertPorts(ctx, ports) {
+				written = append(written, batch[i].written(result.Status, result.Err))
+			}
+			s.sendWritten(ctx, origin, written, results)
+		}
+		batch = batch[:0]
+		ports = ports[:0]
+		written = written[:0]
+	}
+
+	for rec := range in {
+		if rec.duplicate && s.duplicates == MergeDuplicates {
+			// The earlier occurrence of the port may be in the batch, it is written before being merged into
+			if len(batch) > 0 {
+				flush()
+			}
+			var ok bool
+			if rec, ok = s.mergeDuplicate(ctx, s.repo.GetPortByUNLOC, rec, results); !ok {
+				continue
+			}
+		}
+
+		batch = append(batch, rec)
+		if len(batch) == s.batchSize {
+			flush()
+		}
+	}
+	if len(batch) > 0 {
+		flush()
+	}
+}
+
+// mergeDuplicate merges the port of a duplicate record into the one returned by stored, when duplicates
+// are merged, and validates the result. It reports the records it rejects and returns false for them.
+func (s *PortService) mergeDuplicate(ctx context.Context, stored func(context.Context, domain.UNLOCODE) (*domain.Port, error), rec record, results chan<- importResult) (record, bool) {
+	if !rec.duplicate || s.duplicates != MergeDuplicates {
+		return rec, true
+	}
+
+	earlier, err := stored(ctx, rec.port.UNLOC)
+	if err != nil {
+		results <- rec.rejected(StageWrite, err)
+		return rec, false
+	}
+	if earlier != nil {
+		rec.port = earlier.Merge(rec.port)
+	}
+	if err := validatePort(&rec.port); err != nil {
+		results <- rec.rejected(StageValidate, err)
+		return rec, false
+	}
+	return rec, true
+}
+
+// upsertMerged merges the port into the stored one with the merge policy, and writes the result,
+// which it returns. The result is validated first, and not written when it fails with a *domain.ValidationError. When the repository is a ConditionalPortRepository, the merge is written only if the stored port did not
+// change since it was read, and retried up to maxMergeAttempts times otherwise.
+func (s *PortService) upsertMerged(ctx context.Context, port domain.Port) (domain.Port, domain.UpsertStatus, error) {
+	repo, conditional := s.repo.(ConditionalPortRepository)
+	for attempt := 1; ; attempt++ {
+		stored, err := s.repo.GetPortByUNLOC(ctx, port.UNLOC)
+		if err != nil {
+			return port, 0, err
+		}
+		merged := port
+		if stored != nil {
+			merged = s.merge.Merge(*stored, port)
+			if err := validatePort(&merged); err != nil {
+				return merged, 0, err
+			}
+		}
+		if !conditional {
+			status, err := s.repo.UpsertPort(ctx, merged)
+			return merged, status, err
+		}
+
+		status, err := repo.UpsertPortIf(ctx, merged, stored)
+		if !errors.Is(err, domain.ErrPortChanged) || attempt == maxMergeAttempts {
+			return merged, status, err
+		}
+	}
+}
+
+// skipUnchanged reports the records whose port is already stored as is as unchanged, and returns the
+// others, reusing the batch. Records are returned as is when the repository does not store port hashes,
+// or when they cannot be read, leaving it to the writes to find out.
+func (s *PortService) skipUnchanged(ctx context.Context, origin domain.Origin, batch []record, results chan<- importResult) []record {
+	repo, ok := s.repo.(PortHashRepository)
+	if !ok {
+		return batch
+	}
+
+	unlocs := make([]domain.UNLOCODE, len(batch))
+	for i, rec := range batch {
+		unlocs[i] = rec.port.UNLOC
+	}
+	hashes, err := repo.GetPortHashes(ctx, unlocs)
+	if err != nil || len(hashes) != len(batch) {
+		return batch
+	}
+
+	var unchanged []importResult
+	changed := batch[:0]
+	written := make(map[domain.UNLOCODE]bool, len(batch))
+	for i, rec := range batch {
+		// Once a port is written, the stored hash is stale for the later occurrences of its UNLOC
+		// in the batch, so they are written as well
+		if !written[rec.port.UNLOC] && hashes[i] == rec.port.Hash() {
+			unchanged = append(unchanged, importResult{record: rec, status: domain.Unchanged})
+			continue
+		}
+		written[rec.port.UNLOC] = true
+		changed = append(changed, rec)
+	}
+	if len(unchanged) > 0 {
+		s.sendWritten(ctx, origin, unchanged, results)
+	}
+	return changed
+}
+
+// written returns the outcome of writing the record to the repository.
+func (r record) written(status domain.UpsertStatus, err error) importResult {
+	if err != nil {
+		return r.rejected(StageWrite, err)
+	}
+	return importResult{record: r, status: status}
+}
+
+// rejected returns the outcome of the record rejected at the given stage.
+func (r record) rejected(stage ImportStage, err error) importResult {
+	return importResult{record: r, stage: stage, err: err}
+}
+
+// shardFor returns the index of the shard responsible for the given UNLOC.
+func shardFor(unloc domain.UNLOCODE, shards int) int {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(unloc))
+	return int(h.Sum32() % uint32(shards))
+}
+
+// countingReader counts the bytes read from the underlying reader.
+// The count can be read concurrently with reads.
+type countingReader struct {
+	reader io.Reader
+	n      atomic.Int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	r.n.Add(int64(n))
+	return n, err
+}
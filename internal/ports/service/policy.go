@@ -0,0 +1,120 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+)
+
+// minFailureRateSample is the number of processed ports before which MaxFailureRate is not enforced,
+// so that a failure among the first ports of an import does not abort it on its own.
+const minFailureRateSample = 100
+
+// ErrTooManyFailures is returned by imports aborted because of their ErrorPolicy.
+var ErrTooManyFailures = errors.New("too many rejected ports")
+
+// ErrorPolicy decides how many rejected ports, invalid or failing to be written, an import tolerates.
+// An import exceeding its policy stops decoding the input and returns an error wrapping ErrTooManyFailures,
+// while the ports already on their way through the pipeline are dropped rather than written.
+// Decoding errors always abort an import, whatever its policy.
+//
+// The zero value never aborts an import.
+type ErrorPolicy struct {
+	// MaxFailures aborts the import once that many ports were rejected. Zero disables it.
+	MaxFailures int
+	// MaxFailureRate aborts the import once the share of rejected ports among the processed ones
+	// exceeds it, e.g. 0.05 for 5%. It is enforced once minFailureRateSample ports were processed,
+	// and at the end of the import. Zero disables it.
+	MaxFailureRate float64
+}
+
+// FailFast returns the ErrorPolicy aborting an import on its first rejected port.
+func FailFast() ErrorPolicy {
+	return ErrorPolicy{MaxFailures: 1}
+}
+
+// BestEffort returns the ErrorPolicy never aborting an import, which is the default.
+func BestEffort() ErrorPolicy {
+	return ErrorPolicy{}
+}
+
+// check returns an error when the report exceeds the policy.
+// Completed tells whether the import is over, in which case the failure rate is enforced
+// however few ports were processed.
+func (p ErrorPolicy) check(report *ImportReport, completed bool) error {
+	failures := report.Invalid + report.Failed
+	if failures == 0 {
+		return nil
+	}
+
+	if p.MaxFailures > 0 && failures >= p.MaxFailures {
+		return p.exceeded(report, fmt.Sprintf("%d rejected ports, the limit being %d", failures, p.MaxFailures))
+	}
+
+	processed := report.Processed()
+	if p.MaxFailureRate > 0 && (completed || processed >= minFailureRateSample) {
+		if rate := float64(failures) / float64(processed); rate > p.MaxFailureRate {
+			return p.exceeded(report, fmt.Sprintf("%d rejected ports out of %d (%.1f%%), the limit being %.1f%%",
+				failures, processed, rate*100, p.MaxFailureRate*100))
+		}
+	}
+	return nil
+}
+
+func (p ErrorPolicy) exceeded(report *ImportReport, reason string) error {
+	err := fmt.Errorf("%w: %s", ErrTooManyFailures, reason)
+	if len(report.Failures) > 0 {
+		first := report.Failures[0]
+		err = fmt.Errorf("%w, first failure: %s: %s", err, first.UNLOC, first.Reason)
+	}
+	return err
+}
+
+// policyGate lets the pipeline stages drop the records left once an import exceeds its ErrorPolicy.
+// The stages count the ports they reject, so that the records following the rejection reaching MaxFailures
+// are dropped right away rather than once the report of the rejection reached the policy check, which
+// also catches the failure rate and aborts the gate.
+type policyGate struct {
+	maxFailures int64
+	rejected    atomic.Int64
+	// aborted is closed once the import is aborted.
+	aborted   chan struct{}
+	abortOnce sync.Once
+}
+
+func newPolicyGate(policy ErrorPolicy) *policyGate {
+	return &policyGate{maxFailures: int64(policy.MaxFailures), aborted: make(chan struct{})}
+}
+
+// reject counts a port rejected by a pipeline stage.
+func (g *policyGate) reject() {
+	g.rejected.Add(1)
+}
+
+// abort aborts the import.
+func (g *policyGate) abort() {
+	g.abortOnce.Do(func() { close(g.aborted) })
+}
+
+// closed reports whether the import is aborted, or the ports rejected reached MaxFailures.
+func (g *policyGate) closed() bool {
+	if g.maxFailures > 0 && g.rejected.Load() >= g.maxFailures {
+		return true
+	}
+	select {
+	case <-g.aborted:
+		return true
+	default:
+		return false
+	}
+}
+
+// drop reports the record as dropped when the gate is closed, and returns whether it was.
+func (g *policyGate) drop(rec record, results chan<- importResult) bool {
+	if !g.closed() {
+		return false
+	}
+	results <- importResult{record: rec, dropped: true}
+	return true
+}
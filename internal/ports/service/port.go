@@ -2,86 +2,378 @@ package service
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"io"
-	"os"
-
-	log "github.com/sirupsen/logrus"
+	"runtime"
+	"runtime/debug"
+	"sync"
+	"time"
 
 	"ports-service/internal/ports/domain"
+	"ports-service/internal/ports/format"
 )
 
 // PortRepository is an interface for accessing port data.
 type PortRepository interface {
-	GetPortByUNLOC(ctx context.Context, unloc string) (*domain.Port, error)
-	UpsertPort(ctx context.Context, port domain.Port) error
+	GetPortByUNLOC(ctx context.Context, unloc domain.UNLOCODE) (*domain.Port, error)
+	// UpsertPort inserts or updates a port and reports whether it was inserted, updated or left unchanged.
+	UpsertPort(ctx context.Context, port domain.Port) (domain.UpsertStatus, error)
+	// DeletePorts removes the ports with the given UNLOCs, ignoring the ones not stored.
+	DeletePorts(ctx context.Context, unlocs []domain.UNLOCODE) error
+	// ScanUNLOCs calls fn with the UNLOCs of the stored ports, a page at a time, until fn fails.
+	// Ports deleted by fn do not disturb the scan, and a port may be reported more than once.
+	ScanUNLOCs(ctx context.Context, fn func(unlocs []domain.UNLOCODE) error) error
+}
+
+// BatchPortRepository is a PortRepository able to upsert several ports at once.
+// LoadPorts uses it automatically when the repository implements it.
+type BatchPortRepository interface {
+	PortRepository
+	// UpsertPorts inserts or updates the given ports.
+	// It returns one result per port, in the same order, so each failed write is reported on its own.
+	UpsertPorts(ctx context.Context, ports []domain.Port) []domain.UpsertResult
+}
+
+// PortHashRepository is implemented by repositories storing the hash of each port along with it.
+// LoadPorts then skips writing the ports whose hash did not change, reporting them as unchanged.
+type PortHashRepository interface {
+	// GetPortHashes returns the domain.Port Hash of the ports stored with the given UNLOCs,
+	// in the same order, and an empty hash for the ports not stored.
+	GetPortHashes(ctx context.Context, unlocs []domain.UNLOCODE) ([]string, error)
+}
+
+// ConditionalPortRepository is implemented by repositories able to write a port only when the stored one
+// did not change since it was read. Imports merging ports with a domain.MergePolicy use it so that
+// the ports written concurrently by other importers are not overwritten, see WithMergePolicy.
+type ConditionalPortRepository interface {
+	// UpsertPortIf inserts or updates a port, provided that the stored port is still the previous one,
+	// nil meaning that it is not stored. It returns domain.ErrPortChanged otherwise.
+	UpsertPortIf(ctx context.Context, port domain.Port, previous *domain.Port) (domain.UpsertStatus, error)
+}
+
+// CheckpointRepository is implemented by repositories able to persist import checkpoints.
+// LoadSource resumes interrupted imports when the repository implements it.
+type CheckpointRepository interface {
+	GetCheckpoint(ctx context.Context, source string) (*domain.Checkpoint, error)
+	SaveCheckpoint(ctx context.Context, checkpoint domain.Checkpoint) error
+	DeleteCheckpoint(ctx context.Context, source string) error
+}
+
+// Source is an identified input of an import.
+type Source struct {
+	// Name identifies the source, e.g. its file path.
+	Name string
+	// Fingerprint identifies the content of the source.
+	// Imports of sources with a name and a fingerprint are checkpointed, so that an
+	// interrupted import resumes where it stopped as long as the content is the same.
+	Fingerprint string
+	// Size is the size of the source in bytes, zero when unknown. Progress is reported against it.
+	Size int64
+	// Format is the format of the content, detected from its beginning when not set.
+	Format format.Format
+	// Reader provides the content of the source.
+	Reader io.Reader
 }
 
 // PortService provides methods for managing ports.
 type PortService struct {
-	repo PortRepository
+	repo              PortRepository
+	workers           int
+	batchSize         int
+	diffPartitionSize int
+	dryRun            bool
+	fullSync          bool
+	duplicates        DuplicatePolicy
+	missingSources    MissingSourcePolicy
+	merge             domain.MergePolicy
+	memoryBudget      int64
+	drainTimeout      time.Duration
+	onFailure         []FailureHandler
+	onProgress        []progressHandler
+	policy            ErrorPolicy
+}
+
+// Option configures a PortService.
+type Option func(*PortService)
+
+// WithWorkers sets the number of validation and write workers used by LoadPorts.
+// Values lower than one are ignored.
+func WithWorkers(workers int) Option {
+	return func(s *PortService) {
+		if workers > 0 {
+			s.workers = workers
+		}
+	}
+}
+
+// WithBatchSize sets the maximum number of ports written at once to a BatchPortRepository.
+// Values lower than one are ignored.
+func WithBatchSize(size int) Option {
+	return func(s *PortService) {
+		if size > 0 {
+			s.batchSize = size
+		}
+	}
+}
+
+// WithDiffPartitionSize sets the maximum number of ports of a source held in memory at once by a diff.
+// Values lower than one are ignored.
+func WithDiffPartitionSize(size int) Option {
+	return func(s *PortService) {
+		if size > 0 {
+			s.diffPartitionSize = size
+		}
+	}
+}
+
+// WithMemoryBudget sets the memory, in bytes, that imports are expected to stay within.
+// It is enforced as the soft memory limit of the Go runtime for the duration of each import, see
+// debug.SetMemoryLimit, and bounds the ports going through the pipeline to a quarter of it,
+// slowing decoding down when they are not written fast enough. The pipeline is still let to hold
+// enough ports to fill a batch per worker, which small budgets with many workers or large ports exceed.
+// As the memory limit is global to the process, imports with a budget should not run concurrently.
+// Values lower than one are ignored.
+func WithMemoryBudget(bytes int64) Option {
+	return func(s *PortService) {
+		if bytes > 0 {
+			s.memoryBudget = bytes
+		}
+	}
+}
+
+// WithDrainTimeout sets the time given to the ports already decoded to be written once an import
+// is canceled, after which the writes left are canceled and reported as failed.
+// Values lower than one are ignored.
+func WithDrainTimeout(timeout time.Duration) Option {
+	return func(s *PortService) {
+		if timeout > 0 {
+			s.drainTimeout = timeout
+		}
+	}
+}
+
+// WithDryRun makes imports decode and validate the ports without writing them to the repository.
+// Valid ports are then reported as such rather than as inserted, updated or unchanged.
+func WithDryRun() Option {
+	return func(s *PortService) {
+		s.dryRun = true
+	}
+}
+
+// WithDuplicatePolicy sets the policy applied to the ports repeating an UNLOC found earlier in the source.
+// Imports with an unknown policy fail.
+func WithDuplicatePolicy(policy DuplicatePolicy) Option {
+	return func(s *PortService) {
+		s.duplicates = policy
+	}
+}
+
+// WithMergePolicy sets how the ports imported are merged into the stored ones, field by field.
+// Stored ports are replaced as a whole by default. With any other policy, each port is read before
+// being written, one at a time, and written only if it did not change in between when the repository
+// is a ConditionalPortRepository, the merge being retried otherwise.
+// Imports with an invalid policy fail.
+func WithMergePolicy(policy domain.MergePolicy) Option {
+	return func(s *PortService) {
+		s.merge = policy
+	}
+}
+
+// WithFullSync makes imports delete the stored ports missing from their source once they succeed,
+// so that the repository holds the ports of the latest source only.
+// Imports which are interrupted, aborted or fail never delete ports, and neither do dry runs.
+func WithFullSync() Option {
+	return func(s *PortService) {
+		s.fullSync = true
+	}
+}
+
+// FailureHandler is called with every port failing to be imported, as opposed to the capped
+// sample of the ImportReport, e.g. to store them in a dead-letter file.
+// Handlers are never called concurrently.
+type FailureHandler func(failure ImportFailure) error
+
+// WithFailureHandler adds a handler of the ports failing to be imported.
+// A handler returning an error is not called anymore for the rest of the import,
+// which then returns the error once completed.
+func WithFailureHandler(handler FailureHandler) Option {
+	return func(s *PortService) {
+		s.onFailure = append(s.onFailure, handler)
+	}
+}
+
+// WithErrorPolicy sets the number of rejected ports tolerated by imports before they are aborted.
+// Imports never abort because of rejected ports by default.
+func WithErrorPolicy(policy ErrorPolicy) Option {
+	return func(s *PortService) {
+		s.policy = policy
+	}
 }
 
 // NewPortService creates a new instance of PortService.
-func NewPortService(repo PortRepository) *PortService {
-	return &PortService{
-		repo: repo,
+func NewPortService(repo PortRepository, opts ...Option) *PortService {
+	s := &PortService{
+		repo:              repo,
+		workers:           runtime.NumCPU(),
+		batchSize:         defaultBatchSize,
+		diffPartitionSize: defaultDiffPartitionSize,
+		duplicates:        LastWins,
+		missingSources:    FailMissingSources,
+		drainTimeout:      defaultDrainTimeout,
+	}
+	for _, opt := range opts {
+		opt(s)
 	}
+	return s
 }
 
 // LoadPorts loads ports from the given reader.
-// It expects the input to be in JSON format.
-// Each JSON object should represent a single port.
+// It expects the input to be in JSON format, either a single object holding the ports
+// keyed by their UNLOC, an array of ports or newline delimited ports (NDJSON), the last
+// two holding the UNLOC of each port in its "unloc" field. UN/LOCODE CSV releases are
+// supported as well. The format is detected from the beginning of the input.
 // It decodes the JSON data from the reader and upserts the ports into the repository.
-func (s *PortService) LoadPorts(ctx context.Context, reader io.Reader, terminate chan os.Signal) error {
-	dec := json.NewDecoder(reader)
+//
+// Decoding, validation and writing run as separate pipeline stages. Ports are
+// sharded by UNLOC across the workers, so repeated keys are always handled by
+// the same worker in file order, and the last occurrence in the file wins
+// unless another DuplicatePolicy is set.
+//
+// Invalid ports and failed writes do not stop the import unless its ErrorPolicy says so,
+// they are reported in the returned ImportReport, which is also returned when the import fails.
+//
+// Canceling the context stops reading the input, while the ports already decoded are still
+// written until the drain timeout elapses, see WithDrainTimeout. The import then returns the
+// report of its partial progress along with the context error. Every port decoded is reported,
+// the ports whose write was canceled being reported as failed.
+func (s *PortService) LoadPorts(ctx context.Context, reader io.Reader) (ImportReport, error) {
+	return s.LoadSource(ctx, Source{Reader: reader})
+}
+
+// LoadSource loads ports from the given source, as LoadPorts does.
+// With WithFullSync, the stored ports missing from the source are deleted once the import succeeds.
+// When the source has a name and a fingerprint and the repository is a CheckpointRepository,
+// the progress of the import is checkpointed: an import canceled or failing
+// is resumed by the next import of the same source, unless its content changed.
+// Dry runs are not checkpointed.
+func (s *PortService) LoadSource(ctx context.Context, src Source) (ImportReport, error) {
+	start := time.Now()
+	if err := s.duplicates.validate(); err != nil {
+		return ImportReport{}, err
+	}
+	if err := s.merge.Validate(); err != nil {
+		return ImportReport{}, fmt.Errorf("invalid merge policy: %w", err)
+	}
 
-	t, err := dec.Token()
+	report, seen, err := s.load(ctx, src, newRunID())
+	if s.fullSync && !s.dryRun && err == nil {
+		report.Deleted, err = s.syncPorts(ctx, seen)
+	}
+	report.Elapsed = time.Since(start)
+	return report, err
+}
+
+// load imports the source as part of the given run, and returns the UNLOCs found in it along with
+// the report of the import.
+func (s *PortService) load(ctx context.Context, src Source, runID string) (ImportReport, *keySet, error) {
+	start := time.Now()
+	if s.memoryBudget > 0 {
+		defer debug.SetMemoryLimit(debug.SetMemoryLimit(s.memoryBudget))
+	}
+	input := &countingReader{reader: src.Reader}
+
+	dec, err := format.NewDecoder(src.Format, input)
 	if err != nil {
-		return err
+		return ImportReport{RunID: runID, BytesRead: input.n.Load(), Elapsed: time.Since(start)}, nil, err
 	}
-	if t != json.Delim('{') {
-		return fmt.Errorf("expected {, got %v", t)
+
+	checkpoints, err := newCheckpointer(ctx, s.repo, src, s.dryRun, s.policy)
+	if err != nil {
+		return ImportReport{RunID: runID}, nil, fmt.Errorf("failed to load import checkpoint: %w", err)
 	}
 
-	for dec.More() {
-		// Check for termination signal
-		select {
-		case <-terminate:
-			return nil // Gracefully terminate
-		default:
-			// Continue processing
-		}
+	// The ports decoded before the import is canceled are still written, and their outcome recorded
+	writeCtx, stopDraining := drain(ctx, s.drainTimeout)
+	defer stopDraining()
 
-		t, err := dec.Token()
-		if err != nil {
-			return err
+	report := ImportReport{RunID: runID}
+	origin := domain.Origin{Source: src.Name, Checksum: src.Fingerprint, RunID: runID}
+	var policyErr error
+	handlers := newFailureHandlers(s.onFailure)
+	progress := newProgressTracker(s.onProgress, src, input)
+	results := make(chan importResult, stageBufferSize)
+	reported := make(chan struct{})
+	gate := newPolicyGate(s.policy)
+	flight := newInFlight(s.memoryBudget/inFlightShare, minInFlight(s.workers, s.batchSize))
+	go func() {
+		defer close(reported)
+		for result := range results {
+			flight.release(result.size)
+			if failure := report.record(result, src.Name); failure != nil {
+				handlers.handle(*failure)
+				if policyErr == nil {
+					if policyErr = s.policy.check(&report, false); policyErr != nil {
+						gate.abort()
+					}
+				}
+			}
+			checkpoints.commit(writeCtx, result)
+			progress.update(&report)
 		}
-		key := t.(string)
+	}()
 
-		var port domain.Port
-		if err := dec.Decode(&port); err != nil {
-			return err
-		}
+	decoder := &portDecoder{
+		duplicates:   s.duplicates,
+		inFlight:     flight,
+		abort:        gate.aborted,
+		resumeOffset: checkpoints.resumeOffset(),
+		shards:       make([]chan record, s.workers),
+	}
+	var wg sync.WaitGroup
+	for i := range decoder.shards {
+		decoder.shards[i] = make(chan record, stageBufferSize)
+		validated := make(chan record, stageBufferSize)
 
-		port.UNLOC = key
-		err = s.upsertPort(ctx, port)
-		if err != nil {
-			log.Warnf("failed to upsert port: %v", err)
-			continue
-		}
+		wg.Add(2)
+		go func(in <-chan record) {
+			defer wg.Done()
+			defer close(validated)
+			s.validatePorts(in, validated, results, gate)
+		}(decoder.shards[i])
+		go func() {
+			defer wg.Done()
+			s.writePorts(writeCtx, origin, validated, results, gate)
+		}()
 	}
-	return nil
-}
 
-// upsertPort inserts or updates a port in the repository.
-// It validates the port's data before upserting.
-// If the validation fails, it returns an error.
-func (s *PortService) upsertPort(ctx context.Context, port domain.Port) error {
-	if err := port.Validate(); err != nil {
-		return err
+	err = decoder.decode(ctx, dec)
+	wg.Wait()
+	close(results)
+	<-reported
+	progress.finish()
+
+	if policyErr == nil && err == nil && !decoder.canceled {
+		policyErr = s.policy.check(&report, true)
+	}
+	if policyErr != nil && err == nil {
+		err = fmt.Errorf("import aborted: %w", policyErr)
+	}
+
+	if checkpointErr := checkpoints.finish(writeCtx, err == nil && !decoder.canceled); checkpointErr != nil && err == nil {
+		err = fmt.Errorf("failed to save import checkpoint: %w", checkpointErr)
+	}
+	if handlerErr := handlers.err(); handlerErr != nil && err == nil {
+		err = fmt.Errorf("failed to handle import failure: %w", handlerErr)
+	}
+	if decoder.canceled && err == nil {
+		err = fmt.Errorf("import canceled: %w", ctx.Err())
 	}
 
-	return s.repo.UpsertPort(ctx, port)
+	report.DuplicatePolicy = s.duplicates
+	report.Duplicates = decoder.keys.count
+	report.DuplicateKeys = decoder.keys.keys()
+	report.Skipped = decoder.skipped
+	report.BytesRead = input.n.Load()
+	report.Elapsed = time.Since(start)
+	return report, &decoder.keys.seen, err
 }
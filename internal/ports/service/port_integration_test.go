@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"io"
 	"os"
 	"ports-service/internal/infra/repository/inmemory"
 	"testing"
@@ -30,8 +31,9 @@ func TestPortService_LoadPorts(t *testing.T) {
 	assert.NoError(t, err)
 	defer file.Close()
 
-	err = portService.LoadPorts(ctx, file, nil)
+	report, err := portService.LoadPorts(ctx, file)
 	assert.NoError(t, err)
+	assert.Equal(t, 2, report.Inserted)
 
 	// Retrieve the loaded ports from the repository
 	loadedPort1, err := repo.GetPortByUNLOC(ctx, "AEJEA")
@@ -41,13 +43,14 @@ func TestPortService_LoadPorts(t *testing.T) {
 		Name:        "Jebel Ali",
 		City:        "Jebel Ali",
 		Country:     "United Arab Emirates",
+		CountryCode: "AE",
 		Alias:       []string{},
 		Regions:     []string{},
-		Coordinates: []float64{55.0272904, 24.9857145},
+		Coordinates: &domain.GeoPoint{Lat: 24.9857145, Lon: 55.0272904},
 		Province:    "Dubai",
 		Timezone:    "Asia/Dubai",
 		UNLOC:       "AEJEA",
-		UNLOCs:      []string{"AEJEA"},
+		UNLOCs:      []domain.UNLOCODE{"AEJEA"},
 		Code:        "52051",
 	}
 	assert.True(t, comparePorts(loadedPort1, expectedPort1))
@@ -59,16 +62,25 @@ func TestPortService_LoadPorts(t *testing.T) {
 		Name:        "Jebel Dhanna",
 		City:        "Jebel Dhanna",
 		Country:     "United Arab Emirates",
+		CountryCode: "AE",
 		Alias:       []string{},
 		Regions:     []string{},
-		Coordinates: []float64{52.6126027, 24.1915137},
+		Coordinates: &domain.GeoPoint{Lat: 24.1915137, Lon: 52.6126027},
 		Province:    "Abu Dhabi",
 		Timezone:    "Asia/Dubai",
 		UNLOC:       "AEJED",
-		UNLOCs:      []string{"AEJED"},
+		UNLOCs:      []domain.UNLOCODE{"AEJED"},
 		Code:        "52050",
 	}
 	assert.True(t, comparePorts(loadedPort2, expectedPort2))
+
+	// Loading the same file again leaves the stored ports untouched
+	_, err = file.Seek(0, io.SeekStart)
+	assert.NoError(t, err)
+
+	report, err = portService.LoadPorts(ctx, file)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, report.Unchanged)
 }
 
 func comparePorts(p1, p2 *domain.Port) bool {
@@ -3,10 +3,16 @@ package service_test
 import (
 	"bytes"
 	"context"
-	"os"
-	"os/signal"
-	"syscall"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 
@@ -15,10 +21,15 @@ import (
 )
 
 type mockPortRepository struct {
-	ports map[string]domain.Port
+	ports       map[domain.UNLOCODE]domain.Port
+	checkpoints map[string]domain.Checkpoint
+	mutex       sync.Mutex
 }
 
-func (m *mockPortRepository) GetPortByUNLOC(_ context.Context, unloc string) (*domain.Port, error) {
+func (m *mockPortRepository) GetPortByUNLOC(_ context.Context, unloc domain.UNLOCODE) (*domain.Port, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
 	port, exists := m.ports[unloc]
 	if !exists {
 		return nil, nil
@@ -26,24 +37,174 @@ func (m *mockPortRepository) GetPortByUNLOC(_ context.Context, unloc string) (*d
 	return &port, nil
 }
 
-func (m *mockPortRepository) UpsertPort(_ context.Context, port domain.Port) error {
+func (m *mockPortRepository) UpsertPort(_ context.Context, port domain.Port) (domain.UpsertStatus, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	_, exists := m.ports[port.UNLOC]
 	m.ports[port.UNLOC] = port
+	if exists {
+		return domain.Updated, nil
+	}
+	return domain.Inserted, nil
+}
+
+func (m *mockPortRepository) DeletePorts(_ context.Context, unlocs []domain.UNLOCODE) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	for _, unloc := range unlocs {
+		delete(m.ports, unloc)
+	}
+	return nil
+}
+
+func (m *mockPortRepository) ScanUNLOCs(_ context.Context, fn func(unlocs []domain.UNLOCODE) error) error {
+	m.mutex.Lock()
+	var unlocs []domain.UNLOCODE
+	for unloc := range m.ports {
+		unlocs = append(unlocs, unloc)
+	}
+	m.mutex.Unlock()
+
+	// Report the UNLOCs one at a time, as repositories paginate them
+	for _, unloc := range unlocs {
+		if err := fn([]domain.UNLOCODE{unloc}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *mockPortRepository) GetCheckpoint(_ context.Context, source string) (*domain.Checkpoint, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	checkpoint, exists := m.checkpoints[source]
+	if !exists {
+		return nil, nil
+	}
+	return &checkpoint, nil
+}
+
+func (m *mockPortRepository) SaveCheckpoint(_ context.Context, checkpoint domain.Checkpoint) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.checkpoints[checkpoint.Source] = checkpoint
+	return nil
+}
+
+func (m *mockPortRepository) DeleteCheckpoint(_ context.Context, source string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	delete(m.checkpoints, source)
 	return nil
 }
 
+// mockBatchPortRepository records the batches it receives and fails the writes of the given UNLOCs.
+type mockBatchPortRepository struct {
+	mockPortRepository
+	failing map[domain.UNLOCODE]bool
+	batches [][]domain.UNLOCODE
+}
+
+func (m *mockBatchPortRepository) UpsertPorts(_ context.Context, ports []domain.Port) []domain.UpsertResult {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	results := make([]domain.UpsertResult, len(ports))
+	var batch []domain.UNLOCODE
+	for i, port := range ports {
+		batch = append(batch, port.UNLOC)
+		if m.failing[port.UNLOC] {
+			results[i].Err = errors.New("write failed")
+			continue
+		}
+		m.ports[port.UNLOC] = port
+		results[i].Status = domain.Inserted
+	}
+	m.batches = append(m.batches, batch)
+	return results
+}
+
+// slowPortRepository is a mockPortRepository whose writes take the given delay, unless their context is canceled.
+type slowPortRepository struct {
+	mockPortRepository
+	delay time.Duration
+}
+
+func (m *slowPortRepository) UpsertPort(ctx context.Context, port domain.Port) (domain.UpsertStatus, error) {
+	timer := time.NewTimer(m.delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return m.mockPortRepository.UpsertPort(ctx, port)
+	case <-ctx.Done():
+		return 0, ctx.Err()
+	}
+}
+
+// stallingPortRepository is a mockPortRepository whose writes hang past the first ones until their context
+// is canceled, canceling the import when they start to. Its checkpoints honour their context.
+type stallingPortRepository struct {
+	mockPortRepository
+	writes       atomic.Int64
+	stallAfter   int64
+	cancelImport context.CancelFunc
+}
+
+func (m *stallingPortRepository) UpsertPort(ctx context.Context, port domain.Port) (domain.UpsertStatus, error) {
+	if m.writes.Add(1) > m.stallAfter {
+		m.cancelImport()
+		<-ctx.Done()
+		return 0, ctx.Err()
+	}
+	return m.mockPortRepository.UpsertPort(ctx, port)
+}
+
+func (m *stallingPortRepository) SaveCheckpoint(ctx context.Context, checkpoint domain.Checkpoint) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return m.mockPortRepository.SaveCheckpoint(ctx, checkpoint)
+}
+
+// mockHashPortRepository is a mockBatchPortRepository providing the hashes of its ports.
+type mockHashPortRepository struct {
+	mockBatchPortRepository
+}
+
+func (m *mockHashPortRepository) GetPortHashes(_ context.Context, unlocs []domain.UNLOCODE) ([]string, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	hashes := make([]string, len(unlocs))
+	for i, unloc := range unlocs {
+		if port, exists := m.ports[unloc]; exists {
+			hashes[i] = port.Hash()
+		}
+	}
+	return hashes, nil
+}
+
 func TestPortService_LoadPorts_Success(t *testing.T) {
 	ctx := context.Background()
 
 	repo := &mockPortRepository{
-		ports: make(map[string]domain.Port),
+		ports: make(map[domain.UNLOCODE]domain.Port),
 	}
 
 	portService := service.NewPortService(repo)
 
 	reader := bytes.NewReader([]byte(samplePorts))
 
-	err := portService.LoadPorts(ctx, reader, nil)
+	report, err := portService.LoadPorts(ctx, reader)
 	assert.NoError(t, err)
+	assert.Equal(t, 2, report.Inserted)
+	assert.Equal(t, int64(len(samplePorts)), report.BytesRead)
+	assert.Empty(t, report.Failures)
 
 	portAEJEA, err := repo.GetPortByUNLOC(ctx, "AEJEA")
 	assert.NoError(t, err)
@@ -64,20 +225,24 @@ func TestPortService_LoadPorts_Error(t *testing.T) {
 	ctx := context.Background()
 
 	repo := &mockPortRepository{
-		ports: make(map[string]domain.Port),
+		ports: make(map[domain.UNLOCODE]domain.Port),
 	}
 
 	portService := service.NewPortService(repo)
 
-	inputWithInvalidPort := samplePorts + `
+	inputWithInvalidPort := samplePortsWith(`
 		"InvalidPort": {
 			"name": "Invalid Port"
-		}
-	}`
+		}`)
 
 	reader := bytes.NewReader([]byte(inputWithInvalidPort))
-	err := portService.LoadPorts(ctx, reader, nil)
+	report, err := portService.LoadPorts(ctx, reader)
 	assert.NoError(t, err)
+	assert.Equal(t, 2, report.Inserted)
+	assert.Equal(t, 1, report.Invalid)
+	assert.Len(t, report.Failures, 1)
+	assert.Equal(t, domain.UNLOCODE("INVALIDPORT"), report.Failures[0].UNLOC)
+	assert.Contains(t, report.Failures[0].Reason, "Field validation for 'City'")
 
 	// Verify that the ports without validation errors are still upserted
 	portAEJEA, err := repo.GetPortByUNLOC(ctx, "AEJEA")
@@ -100,33 +265,742 @@ func TestPortService_LoadPorts_Error(t *testing.T) {
 	assert.Nil(t, invalidPort)
 }
 
-func TestPortService_LoadPorts_Graceful_Termination(t *testing.T) {
+func TestPortService_LoadPorts_MalformedCoordinates(t *testing.T) {
+	repo := &mockPortRepository{
+		ports: make(map[domain.UNLOCODE]domain.Port),
+	}
+	portService := service.NewPortService(repo)
+
+	// Malformed coordinates reject their port rather than aborting the import
+	input := samplePortsWith(`"AEAUH": {"name": "Abu Dhabi", "city": "Abu Dhabi", "country": "United Arab Emirates", "coordinates": [54.37]}`)
+	report, err := portService.LoadPorts(context.Background(), strings.NewReader(input))
+	assert.NoError(t, err)
+	assert.Equal(t, 2, report.Inserted)
+	assert.Equal(t, 1, report.Invalid)
+	if assert.Len(t, report.Failures, 1) {
+		assert.Equal(t, domain.UNLOCODE("AEAUH"), report.Failures[0].UNLOC)
+		assert.Contains(t, report.Failures[0].Reason, "'coordinates' tag")
+	}
+}
+
+func TestPortService_LoadPorts_FailureHandler(t *testing.T) {
 	ctx := context.Background()
 
 	repo := &mockPortRepository{
-		ports: make(map[string]domain.Port),
+		ports: make(map[domain.UNLOCODE]domain.Port),
+	}
+
+	var failures []service.ImportFailure
+	handlerErr := errors.New("disk full")
+	portService := service.NewPortService(repo,
+		service.WithFailureHandler(func(failure service.ImportFailure) error {
+			failures = append(failures, failure)
+			return nil
+		}),
+		service.WithFailureHandler(func(failure service.ImportFailure) error {
+			return handlerErr
+		}),
+	)
+
+	input := samplePortsWith(`"AEJEB": {"name": "Invalid Port"}`)
+
+	report, err := portService.LoadPorts(ctx, strings.NewReader(input))
+	assert.ErrorIs(t, err, handlerErr)
+	assert.Equal(t, 2, report.Inserted)
+	assert.Equal(t, 1, report.Invalid)
+
+	if assert.Len(t, failures, 1) {
+		failure := failures[0]
+		assert.Equal(t, domain.UNLOCODE("AEJEB"), failure.UNLOC)
+		assert.Equal(t, service.StageValidate, failure.Stage)
+		assert.True(t, strings.HasPrefix(input[failure.Offset:], `"AEJEB"`))
+		assert.JSONEq(t, `{"name": "Invalid Port"}`, string(failure.Record))
+	}
+}
+
+func TestPortService_LoadPorts_ErrorPolicy(t *testing.T) {
+	// 20 invalid ports out of 220, the first one coming first
+	var input strings.Builder
+	input.WriteString("{")
+	for i := 0; i < 220; i++ {
+		if i > 0 {
+			input.WriteString(",")
+		}
+		if i%11 == 0 {
+			fmt.Fprintf(&input, `"%s": {"name": "Port %d"}`, syntheticUNLOC(i), i)
+		} else {
+			fmt.Fprintf(&input, `"%s": {"name": "Port %d", "city": "City %d", "country": "%s"}`, syntheticUNLOC(i), i, i, syntheticUNLOC(i)[:2])
+		}
+	}
+	input.WriteString("}")
+
+	tests := []struct {
+		name    string
+		policy  service.ErrorPolicy
+		aborted bool
+	}{
+		{name: "best effort", policy: service.BestEffort()},
+		{name: "fail fast", policy: service.FailFast(), aborted: true},
+		{name: "max failures not reached", policy: service.ErrorPolicy{MaxFailures: 21}},
+		{name: "max failures reached", policy: service.ErrorPolicy{MaxFailures: 5}, aborted: true},
+		{name: "max failure rate not exceeded", policy: service.ErrorPolicy{MaxFailureRate: 0.15}},
+		{name: "max failure rate exceeded", policy: service.ErrorPolicy{MaxFailureRate: 0.05}, aborted: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &mockPortRepository{
+				ports: make(map[domain.UNLOCODE]domain.Port),
+			}
+			portService := service.NewPortService(repo, service.WithWorkers(1), service.WithErrorPolicy(tt.policy))
+
+			report, err := portService.LoadPorts(context.Background(), strings.NewReader(input.String()))
+			if !tt.aborted {
+				assert.NoError(t, err)
+				assert.Equal(t, 200, report.Inserted)
+				assert.Equal(t, 20, report.Invalid)
+				return
+			}
+
+			assert.ErrorIs(t, err, service.ErrTooManyFailures)
+			assert.ErrorContains(t, err, "first failure: "+syntheticUNLOC(0))
+			assert.Less(t, report.Processed(), 220)
+		})
+	}
+}
+
+func TestPortService_LoadPorts_BatchesWithSmallMemoryBudget(t *testing.T) {
+	repo := &mockBatchPortRepository{
+		mockPortRepository: mockPortRepository{ports: make(map[domain.UNLOCODE]domain.Port)},
+	}
+	// The budget is reached long before the batches of each worker are full, they must still be written
+	portService := service.NewPortService(repo, service.WithWorkers(8), service.WithMemoryBudget(1<<20))
+
+	alias := strings.Repeat("a", 400)
+	var input strings.Builder
+	for i := 0; i < 3000; i++ {
+		unloc := syntheticUNLOC(i)
+		fmt.Fprintf(&input, `{"unloc": "%s", "name": "Port %d", "city": "City %d", "country": "%s", "alias": ["%s"]}`+"\n", unloc, i, i, unloc[:2], alias)
+	}
+
+	done := make(chan struct{})
+	var report service.ImportReport
+	var err error
+	go func() {
+		defer close(done)
+		report, err = portService.LoadPorts(context.Background(), strings.NewReader(input.String()))
+	}()
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("the import did not finish")
+	}
+	assert.NoError(t, err)
+	assert.Equal(t, 3000, report.Inserted)
+}
+
+func TestPortService_LoadPorts_ErrorPolicyDropsFollowingPorts(t *testing.T) {
+	tests := []struct {
+		name  string
+		batch bool
+	}{
+		{name: "single writes"},
+		{name: "batches", batch: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ports := make(map[domain.UNLOCODE]domain.Port)
+			var repo service.PortRepository = &mockPortRepository{ports: ports}
+			if test.batch {
+				repo = &mockBatchPortRepository{mockPortRepository: mockPortRepository{ports: ports}}
+			}
+			portService := service.NewPortService(repo, service.WithWorkers(1), service.WithErrorPolicy(service.FailFast()))
+
+			// None of the ports following the invalid one is written, though they were decoded before the abort
+			input := strings.Replace(generatePorts(1000), `"city": "City 500"`, `"city": ""`, 1)
+			report, err := portService.LoadPorts(context.Background(), strings.NewReader(input))
+			assert.ErrorIs(t, err, service.ErrTooManyFailures)
+			assert.Equal(t, 1, report.Invalid)
+			assert.Positive(t, report.Dropped)
+			for i := 500; i < 1000; i++ {
+				assert.NotContains(t, ports, domain.UNLOCODE(syntheticUNLOC(i)))
+			}
+		})
+	}
+}
+
+func TestPortService_LoadPorts_ErrorPolicyWithMemoryBudget(t *testing.T) {
+	repo := &mockBatchPortRepository{
+		mockPortRepository: mockPortRepository{ports: make(map[domain.UNLOCODE]domain.Port)},
+	}
+	// The budget lets few ports through the pipeline, the ones dropped by the abort must not block decoding
+	portService := service.NewPortService(repo,
+		service.WithWorkers(1),
+		service.WithMemoryBudget(64<<10),
+		service.WithErrorPolicy(service.ErrorPolicy{MaxFailures: 100}),
+	)
+
+	input := regexp.MustCompile(`"country": "[A-Z]{2}"}`).ReplaceAllString(generatePorts(2000), `"country": ""}`)
+	report, err := portService.LoadPorts(context.Background(), strings.NewReader(input))
+	assert.ErrorIs(t, err, service.ErrTooManyFailures)
+	assert.Less(t, report.Processed(), 2000)
+}
+
+func TestPortService_LoadPorts_DryRun(t *testing.T) {
+	ctx := context.Background()
+
+	repo := &mockPortRepository{
+		ports: make(map[domain.UNLOCODE]domain.Port),
+	}
+
+	var failures []service.ImportFailure
+	portService := service.NewPortService(repo,
+		service.WithDryRun(),
+		service.WithFailureHandler(func(failure service.ImportFailure) error {
+			failures = append(failures, failure)
+			return nil
+		}),
+	)
+
+	input := samplePortsWith(`"AEJEB": {"name": "Invalid Port"}`)
+
+	report, err := portService.LoadPorts(ctx, strings.NewReader(input))
+	assert.NoError(t, err)
+	assert.Equal(t, 2, report.Valid)
+	assert.Equal(t, 1, report.Invalid)
+	assert.Zero(t, report.Inserted)
+
+	// Every invalid port is reported with its field errors
+	assert.Len(t, failures, 1)
+	assert.Equal(t, domain.UNLOCODE("AEJEB"), failures[0].UNLOC)
+	assert.Equal(t, []string{"City", "Country"}, []string{failures[0].Fields[0].Field, failures[0].Fields[1].Field})
+	assert.Equal(t, "required", failures[0].Fields[0].Rule)
+
+	// Nothing is written to the repository
+	assert.Empty(t, repo.ports)
+}
+
+func TestPortService_LoadPorts_DryRunMergesDuplicates(t *testing.T) {
+	repo := &mockPortRepository{
+		ports: make(map[domain.UNLOCODE]domain.Port),
+	}
+	portService := service.NewPortService(repo, service.WithDryRun(), service.WithDuplicatePolicy(service.MergeDuplicates))
+
+	// The sparse duplicate is merged into its earlier occurrence, as nothing is stored
+	input := samplePortsWith(`"AEJEA": {"name": "Jebel Ali Port"}`)
+	report, err := portService.LoadPorts(context.Background(), strings.NewReader(input))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, report.Duplicates)
+	assert.Equal(t, 3, report.Valid)
+	assert.Zero(t, report.Invalid)
+	assert.Empty(t, repo.ports)
+}
+
+func TestPortService_LoadPorts_DryRunMergeWithinMemoryBudget(t *testing.T) {
+	repo := &mockPortRepository{
+		ports: make(map[domain.UNLOCODE]domain.Port),
+	}
+	portService := service.NewPortService(repo,
+		service.WithWorkers(1),
+		service.WithMemoryBudget(1<<20),
+		service.WithDryRun(),
+		service.WithDuplicatePolicy(service.MergeDuplicates),
+	)
+
+	// The first ports are kept to merge their duplicates into, while the budget leaves the last ones out
+	input := strings.TrimSuffix(generatePorts(5000), "}") +
+		fmt.Sprintf(`, "%s": {"name": "Renamed"}, "%s": {"name": "Renamed"}}`, syntheticUNLOC(0), syntheticUNLOC(4000))
+	report, err := portService.LoadPorts(context.Background(), strings.NewReader(input))
+	assert.NoError(t, err)
+	assert.Equal(t, 2, report.Duplicates)
+	assert.Equal(t, 1, report.Unmerged)
+	assert.Equal(t, 5001, report.Valid)
+	if assert.Len(t, report.Failures, 1) {
+		assert.Equal(t, domain.UNLOCODE(syntheticUNLOC(4000)), report.Failures[0].UNLOC)
+	}
+}
+
+func TestPortService_LoadPorts_NDJSON(t *testing.T) {
+	ctx := context.Background()
+
+	repo := &mockPortRepository{
+		ports: make(map[domain.UNLOCODE]domain.Port),
 	}
 
 	portService := service.NewPortService(repo)
 
-	reader := bytes.NewReader([]byte(samplePorts))
+	input := `{"unloc": "AEJEA", "name": "Jebel Ali", "city": "Jebel Ali", "country": "United Arab Emirates"}
+{"unloc": "AEJED", "name": "Jebel Dhanna", "city": "Jebel Dhanna", "country": "United Arab Emirates"}
+{"unloc": "AEJEB", "name": "Invalid Port"}
+`
+
+	report, err := portService.LoadPorts(ctx, strings.NewReader(input))
+	assert.NoError(t, err)
+	assert.Equal(t, 2, report.Inserted)
+	assert.Equal(t, 1, report.Invalid)
+
+	portAEJED, err := repo.GetPortByUNLOC(ctx, "AEJED")
+	assert.NoError(t, err)
+	assert.NotNil(t, portAEJED)
+	assert.Equal(t, "Jebel Dhanna", portAEJED.Name)
+}
+
+func TestPortService_LoadPorts_DuplicateKeys_LastWins(t *testing.T) {
+	ctx := context.Background()
 
-	terminate := make(chan os.Signal, 1)
-	signal.Notify(terminate, syscall.SIGINT, syscall.SIGTERM)
-	// Send a termination signal to terminate the method prematurely
-	terminate <- syscall.SIGTERM
+	repo := &mockPortRepository{
+		ports: make(map[domain.UNLOCODE]domain.Port),
+	}
+
+	portService := service.NewPortService(repo, service.WithWorkers(4))
+
+	var input strings.Builder
+	input.WriteString("{")
+	for i := 0; i < 100; i++ {
+		fmt.Fprintf(&input, `"AEJEA": {"name": "Jebel Ali %d", "city": "Jebel Ali", "country": "United Arab Emirates"},`, i)
+		fmt.Fprintf(&input, `"AEJED": {"name": "Jebel Dhanna %d", "city": "Jebel Dhanna", "country": "United Arab Emirates"},`, i)
+	}
+	input.WriteString(`"AEAJM": {"name": "Ajman", "city": "Ajman", "country": "United Arab Emirates"}}`)
+
+	report, err := portService.LoadPorts(ctx, strings.NewReader(input.String()))
+	assert.NoError(t, err)
+	assert.Equal(t, 3, report.Inserted)
+	assert.Equal(t, 198, report.Updated)
+
+	portAEJEA, err := repo.GetPortByUNLOC(ctx, "AEJEA")
+	assert.NoError(t, err)
+	assert.Equal(t, "Jebel Ali 99", portAEJEA.Name)
+
+	portAEJED, err := repo.GetPortByUNLOC(ctx, "AEJED")
+	assert.NoError(t, err)
+	assert.Equal(t, "Jebel Dhanna 99", portAEJED.Name)
+}
+
+func TestPortService_LoadPorts_DuplicatePolicy(t *testing.T) {
+	ctx := context.Background()
+
+	input := samplePortsWith(`"AEJEA": {"name": "Jebel Ali Port"}`)
+
+	tests := []struct {
+		policy     service.DuplicatePolicy
+		invalid    int
+		storedName string
+		province   string
+		stage      service.ImportStage
+	}{
+		{policy: service.LastWins, invalid: 1, storedName: "Jebel Ali", province: "Dubai", stage: service.StageValidate},
+		{policy: service.FirstWins, storedName: "Jebel Ali", province: "Dubai"},
+		{policy: service.MergeDuplicates, storedName: "Jebel Ali Port", province: "Dubai"},
+		{policy: service.RejectDuplicates, invalid: 1, storedName: "Jebel Ali", province: "Dubai", stage: service.StageDuplicate},
+	}
+
+	for _, test := range tests {
+		t.Run(string(test.policy), func(t *testing.T) {
+			repo := &mockBatchPortRepository{
+				mockPortRepository: mockPortRepository{ports: make(map[domain.UNLOCODE]domain.Port)},
+			}
+			portService := service.NewPortService(repo, service.WithWorkers(1), service.WithDuplicatePolicy(test.policy))
+
+			report, err := portService.LoadPorts(ctx, strings.NewReader(input))
+			assert.NoError(t, err)
+			assert.Equal(t, test.policy, report.DuplicatePolicy)
+			assert.Equal(t, 1, report.Duplicates)
+			assert.Equal(t, test.invalid, report.Invalid)
+			if test.invalid > 0 {
+				assert.Equal(t, test.stage, report.Failures[0].Stage)
+			}
+
+			// Both occurrences of the key are reported
+			if assert.Len(t, report.DuplicateKeys, 1) {
+				key := report.DuplicateKeys[0]
+				assert.Equal(t, domain.UNLOCODE("AEJEA"), key.UNLOC)
+				if assert.Len(t, key.Offsets, 2) {
+					assert.Less(t, key.Offsets[0], key.Offsets[1])
+					assert.True(t, strings.HasPrefix(input[key.Offsets[0]:], `"AEJEA"`))
+					assert.True(t, strings.HasPrefix(input[key.Offsets[1]:], `"AEJEA"`))
+				}
+			}
+
+			port, err := repo.GetPortByUNLOC(ctx, "AEJEA")
+			assert.NoError(t, err)
+			assert.Equal(t, test.storedName, port.Name)
+			assert.Equal(t, test.province, port.Province)
+		})
+	}
+
+	_, err := service.NewPortService(&mockPortRepository{}, service.WithDuplicatePolicy("random")).LoadPorts(ctx, strings.NewReader(input))
+	assert.ErrorContains(t, err, "unknown duplicate policy")
+}
+
+func TestPortService_LoadPorts_Batches(t *testing.T) {
+	ctx := context.Background()
+
+	repo := &mockBatchPortRepository{
+		mockPortRepository: mockPortRepository{ports: make(map[domain.UNLOCODE]domain.Port)},
+		failing:            map[domain.UNLOCODE]bool{"AEJEA": true},
+	}
+
+	portService := service.NewPortService(repo, service.WithWorkers(1), service.WithBatchSize(2))
+
+	input := samplePortsWith(`"AEAJM": {"name": "Ajman", "city": "Ajman", "country": "United Arab Emirates"}`)
 
-	err := portService.LoadPorts(ctx, reader, terminate)
+	report, err := portService.LoadPorts(ctx, strings.NewReader(input))
 	assert.NoError(t, err)
+	assert.Equal(t, 2, report.Inserted)
+	assert.Equal(t, 1, report.Failed)
+	if assert.Len(t, report.Failures, 1) {
+		failure := report.Failures[0]
+		assert.Equal(t, domain.UNLOCODE("AEJEA"), failure.UNLOC)
+		assert.Equal(t, service.StageWrite, failure.Stage)
+		assert.Equal(t, "write failed", failure.Reason)
+		assert.Contains(t, string(failure.Record), `"name": "Jebel Ali"`)
+	}
+
+	assert.Equal(t, [][]domain.UNLOCODE{{"AEJEA", "AEJED"}, {"AEAJM"}}, repo.batches)
 
-	// Verify that no ports are upserted due to premature termination
+	// Verify that a failed write does not prevent the other ports of the batch from being written
 	portAEJEA, err := repo.GetPortByUNLOC(ctx, "AEJEA")
 	assert.NoError(t, err)
 	assert.Nil(t, portAEJEA)
 
 	portAEJED, err := repo.GetPortByUNLOC(ctx, "AEJED")
 	assert.NoError(t, err)
-	assert.Nil(t, portAEJED)
+	assert.NotNil(t, portAEJED)
+
+	portAEAJM, err := repo.GetPortByUNLOC(ctx, "AEAJM")
+	assert.NoError(t, err)
+	assert.NotNil(t, portAEAJM)
+}
+
+func TestPortService_LoadPorts_SkipsUnchangedPorts(t *testing.T) {
+	ctx := context.Background()
+
+	repo := &mockHashPortRepository{
+		mockBatchPortRepository: mockBatchPortRepository{
+			mockPortRepository: mockPortRepository{ports: make(map[domain.UNLOCODE]domain.Port)},
+		},
+	}
+
+	portService := service.NewPortService(repo, service.WithWorkers(1), service.WithBatchSize(10))
+
+	report, err := portService.LoadPorts(ctx, strings.NewReader(samplePorts))
+	assert.NoError(t, err)
+	assert.Equal(t, 2, report.Inserted)
+
+	// Only the renamed port is written again, along with the repeated one
+	repo.batches = nil
+	input := strings.Replace(samplePorts, `"name": "Jebel Dhanna"`, `"name": "Jebel Dhanna Port"`, 1)
+	input = strings.TrimSuffix(strings.TrimSpace(input), "}") + `,
+		"AEJEA": {"name": "Jebel Ali Port", "city": "Jebel Ali", "country": "United Arab Emirates"},
+		"AEJEA": {"name": "Jebel Ali", "city": "Jebel Ali", "country": "United Arab Emirates"}}`
+
+	report, err = portService.LoadPorts(ctx, strings.NewReader(input))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, report.Unchanged)
+	assert.Equal(t, [][]domain.UNLOCODE{{"AEJED", "AEJEA", "AEJEA"}}, repo.batches)
+
+	portAEJEA, err := repo.GetPortByUNLOC(ctx, "AEJEA")
+	assert.NoError(t, err)
+	assert.Equal(t, "Jebel Ali", portAEJEA.Name)
+	assert.Empty(t, portAEJEA.Province)
+}
+
+func TestPortService_LoadPorts_FullSync(t *testing.T) {
+	ctx := context.Background()
+
+	stale := domain.Port{UNLOC: "AEAJM", Name: "Ajman", City: "Ajman", Country: "United Arab Emirates"}
+	newRepo := func() *mockPortRepository {
+		return &mockPortRepository{
+			ports: map[domain.UNLOCODE]domain.Port{stale.UNLOC: stale},
+		}
+	}
+
+	t.Run("deletes the ports missing from the source", func(t *testing.T) {
+		repo := newRepo()
+		portService := service.NewPortService(repo, service.WithFullSync())
+
+		report, err := portService.LoadPorts(ctx, strings.NewReader(samplePorts))
+		assert.NoError(t, err)
+		assert.Equal(t, 2, report.Inserted)
+		assert.Equal(t, 1, report.Deleted)
+
+		port, err := repo.GetPortByUNLOC(ctx, stale.UNLOC)
+		assert.NoError(t, err)
+		assert.Nil(t, port)
+	})
+
+	t.Run("keeps the invalid ports of the source", func(t *testing.T) {
+		repo := newRepo()
+		portService := service.NewPortService(repo, service.WithFullSync())
+
+		report, err := portService.LoadPorts(ctx, strings.NewReader(samplePortsWith(`"AEAJM": {"name": "Ajman"}`)))
+		assert.NoError(t, err)
+		assert.Equal(t, 1, report.Invalid)
+		assert.Zero(t, report.Deleted)
+		assert.Contains(t, repo.ports, stale.UNLOC)
+	})
+
+	t.Run("does not delete after an aborted import", func(t *testing.T) {
+		repo := newRepo()
+		portService := service.NewPortService(repo, service.WithFullSync(), service.WithErrorPolicy(service.FailFast()))
+
+		_, err := portService.LoadPorts(ctx, strings.NewReader(samplePortsWith(`"AEJEB": {"name": "Invalid Port"}`)))
+		assert.ErrorIs(t, err, service.ErrTooManyFailures)
+		assert.Contains(t, repo.ports, stale.UNLOC)
+	})
+
+	t.Run("does not delete after a decoding error", func(t *testing.T) {
+		repo := newRepo()
+		portService := service.NewPortService(repo, service.WithFullSync())
+
+		_, err := portService.LoadPorts(ctx, strings.NewReader(strings.TrimSuffix(strings.TrimSpace(samplePorts), "}")))
+		assert.Error(t, err)
+		assert.Contains(t, repo.ports, stale.UNLOC)
+	})
+
+	t.Run("keeps the ports skipped by a resumed import", func(t *testing.T) {
+		repo := newRepo()
+		repo.ports["AEJEA"] = domain.Port{UNLOC: "AEJEA", Name: "Jebel Ali", City: "Jebel Ali", Country: "United Arab Emirates"}
+		// The checkpoint is past the first port only
+		repo.checkpoints = map[string]domain.Checkpoint{
+			"ports.json": {Source: "ports.json", Fingerprint: "fingerprint", Offset: int64(strings.Index(samplePorts, `"AEJEA"`)) + 1},
+		}
+		portService := service.NewPortService(repo, service.WithFullSync())
+
+		src := service.Source{Name: "ports.json", Fingerprint: "fingerprint", Reader: strings.NewReader(samplePorts)}
+		report, err := portService.LoadSource(ctx, src)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, report.Skipped)
+		assert.Equal(t, 1, report.Deleted)
+		assert.Contains(t, repo.ports, domain.UNLOCODE("AEJEA"))
+		assert.NotContains(t, repo.ports, stale.UNLOC)
+	})
+
+	t.Run("does not delete all the ports for an empty source", func(t *testing.T) {
+		repo := newRepo()
+		portService := service.NewPortService(repo, service.WithFullSync())
+
+		_, err := portService.LoadPorts(ctx, strings.NewReader("{}"))
+		assert.Error(t, err)
+		assert.Contains(t, repo.ports, stale.UNLOC)
+	})
+}
+
+func TestPortService_LoadSource_ResumesFromCheckpoint(t *testing.T) {
+	ctx := context.Background()
+
+	repo := &mockPortRepository{
+		ports:       make(map[domain.UNLOCODE]domain.Port),
+		checkpoints: make(map[string]domain.Checkpoint),
+	}
+
+	portService := service.NewPortService(repo, service.WithWorkers(4))
+
+	input := generatePorts(5000)
+
+	// Cancel the first import once half of the input is read
+	canceledCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	src := service.Source{
+		Name:        "ports.json",
+		Fingerprint: "fingerprint",
+		Reader:      &cancelingReader{data: []byte(input), at: len(input) / 2, cancel: cancel},
+	}
+	report, err := portService.LoadSource(canceledCtx, src)
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Less(t, report.Processed(), 5000)
+
+	checkpoint, err := repo.GetCheckpoint(ctx, "ports.json")
+	assert.NoError(t, err)
+	assert.NotNil(t, checkpoint)
+	assert.Positive(t, checkpoint.Offset)
+
+	// The next import of the same content skips the committed ports
+	src.Reader = strings.NewReader(input)
+	report, err = portService.LoadSource(ctx, src)
+	assert.NoError(t, err)
+	assert.Positive(t, report.Skipped)
+	assert.Equal(t, 5000, report.Skipped+report.Processed())
+	assert.Len(t, repo.ports, 5000)
+
+	// A completed import removes its checkpoint
+	checkpoint, err = repo.GetCheckpoint(ctx, "ports.json")
+	assert.NoError(t, err)
+	assert.Nil(t, checkpoint)
+}
+
+func TestPortService_LoadSource_DoesNotResumePastRejectedPorts(t *testing.T) {
+	ctx := context.Background()
+	repo := &mockPortRepository{
+		ports:       make(map[domain.UNLOCODE]domain.Port),
+		checkpoints: make(map[string]domain.Checkpoint),
+	}
+	portService := service.NewPortService(repo, service.WithErrorPolicy(service.FailFast()))
+
+	// The import aborted by its policy is aborted again by the next import of the same file
+	input := strings.Replace(generatePorts(3000), `"city": "City 1500"`, `"city": ""`, 1)
+	for run := 0; run < 2; run++ {
+		src := service.Source{Name: "ports.json", Fingerprint: "fingerprint", Reader: strings.NewReader(input)}
+		report, err := portService.LoadSource(ctx, src)
+		assert.ErrorIs(t, err, service.ErrTooManyFailures, "run %d", run)
+		assert.Equal(t, 1, report.Invalid, "run %d", run)
+	}
+}
+
+func TestPortService_LoadSource_SavesCheckpointAfterDrainTimeout(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	repo := &stallingPortRepository{
+		mockPortRepository: mockPortRepository{
+			ports:       make(map[domain.UNLOCODE]domain.Port),
+			checkpoints: make(map[string]domain.Checkpoint),
+		},
+		stallAfter:   1500,
+		cancelImport: cancel,
+	}
+	portService := service.NewPortService(repo, service.WithWorkers(1), service.WithDrainTimeout(10*time.Millisecond))
+
+	// The writes canceled by the drain timeout do not prevent the progress made before from being saved
+	input := generatePorts(5000)
+	src := service.Source{Name: "ports.json", Fingerprint: "fingerprint", Reader: strings.NewReader(input)}
+	report, err := portService.LoadSource(ctx, src)
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.NotContains(t, err.Error(), "checkpoint")
+	assert.Equal(t, 1500, report.Inserted)
+
+	repo.stallAfter = math.MaxInt64
+	src.Reader = strings.NewReader(input)
+	report, err = portService.LoadSource(context.Background(), src)
+	assert.NoError(t, err)
+	assert.Equal(t, 1500, report.Skipped)
+	assert.Len(t, repo.ports, 5000)
+}
+
+func TestPortService_LoadSource_DiscardsCheckpointOfChangedSource(t *testing.T) {
+	ctx := context.Background()
+
+	repo := &mockPortRepository{
+		ports: make(map[domain.UNLOCODE]domain.Port),
+		checkpoints: map[string]domain.Checkpoint{
+			"ports.json": {Source: "ports.json", Fingerprint: "previous", Offset: int64(len(samplePorts))},
+		},
+	}
+
+	portService := service.NewPortService(repo)
+
+	src := service.Source{Name: "ports.json", Fingerprint: "current", Reader: strings.NewReader(samplePorts)}
+	report, err := portService.LoadSource(ctx, src)
+	assert.NoError(t, err)
+	assert.Zero(t, report.Skipped)
+	assert.Equal(t, 2, report.Inserted)
+}
+
+func TestPortService_LoadPorts_Canceled(t *testing.T) {
+	t.Run("does not read a canceled input", func(t *testing.T) {
+		repo := &mockPortRepository{ports: make(map[domain.UNLOCODE]domain.Port)}
+		portService := service.NewPortService(repo)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		report, err := portService.LoadPorts(ctx, bytes.NewReader([]byte(samplePorts)))
+		assert.ErrorIs(t, err, context.Canceled)
+		assert.Zero(t, report.Processed())
+		assert.Empty(t, repo.ports)
+	})
+
+	t.Run("writes the ports in flight", func(t *testing.T) {
+		repo := &slowPortRepository{mockPortRepository: mockPortRepository{ports: make(map[domain.UNLOCODE]domain.Port)}, delay: time.Millisecond}
+		portService := service.NewPortService(repo, service.WithWorkers(2))
+
+		input := generatePorts(2000)
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		report, err := portService.LoadPorts(ctx, &cancelingReader{data: []byte(input), at: len(input) / 4, cancel: cancel})
+		assert.ErrorIs(t, err, context.Canceled)
+		assert.Positive(t, report.Inserted)
+		assert.Less(t, report.Inserted, 2000)
+		assert.Zero(t, report.Failed)
+		// Every port decoded before the cancellation was written
+		assert.Len(t, repo.ports, report.Inserted)
+	})
+
+	t.Run("reports the writes canceled after the drain timeout", func(t *testing.T) {
+		repo := &slowPortRepository{mockPortRepository: mockPortRepository{ports: make(map[domain.UNLOCODE]domain.Port)}, delay: time.Hour}
+		portService := service.NewPortService(repo, service.WithWorkers(2), service.WithDrainTimeout(10*time.Millisecond))
+
+		input := generatePorts(2000)
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		// Cancel past the beginning of the input, which is read to detect its format
+		report, err := portService.LoadPorts(ctx, &cancelingReader{data: []byte(input), at: 5000, cancel: cancel})
+		assert.ErrorIs(t, err, context.Canceled)
+		assert.Positive(t, report.Failed)
+		assert.Equal(t, report.Failed, report.Processed())
+		assert.Equal(t, service.StageWrite, report.Failures[0].Stage)
+		assert.Empty(t, repo.ports)
+	})
+}
+
+// generatePorts returns a JSON object of n valid ports.
+func generatePorts(n int) string {
+	var input strings.Builder
+	input.WriteString("{")
+	for i := 0; i < n; i++ {
+		if i > 0 {
+			input.WriteString(",")
+		}
+		unloc := syntheticUNLOC(i)
+		fmt.Fprintf(&input, `"%s": {"name": "Port %d", "city": "City %d", "country": "%s"}`, unloc, i, i, unloc[:2])
+	}
+	input.WriteString("}")
+	return input.String()
+}
+
+// syntheticUNLOC returns a distinct well-formed UNLOC for each number, whose country is
+// a country of the reference table.
+func syntheticUNLOC(i int) string {
+	const alphabet = "ABCDEFGHIJKLMNOPQRSTUVWXYZ23456789"
+	unloc := make([]byte, 5)
+	for j := len(unloc) - 1; j >= 2; j-- {
+		unloc[j] = alphabet[i%len(alphabet)]
+		i /= len(alphabet)
+	}
+	countries := domain.Countries()
+	copy(unloc, countries[i%len(countries)].Alpha2)
+	return string(unloc)
+}
+
+// cancelingReader reads data in small chunks and cancels the import once the given offset is read.
+type cancelingReader struct {
+	data   []byte
+	read   int
+	at     int
+	cancel context.CancelFunc
+}
+
+func (r *cancelingReader) Read(p []byte) (int, error) {
+	if r.read == len(r.data) {
+		return 0, io.EOF
+	}
+	if len(p) > 256 {
+		p = p[:256]
+	}
+	n := copy(p, r.data[r.read:])
+	if r.read < r.at && r.read+n >= r.at {
+		r.cancel()
+	}
+	r.read += n
+	return n, nil
+}
+
+// samplePortsWith returns samplePorts with the given port entries appended to the object.
+func samplePortsWith(entries string) string {
+	return strings.TrimSuffix(strings.TrimSpace(samplePorts), "}") + "," + entries + "}"
 }
 
 var samplePorts = `{
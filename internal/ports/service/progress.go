@@ -0,0 +1,205 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Progress describes how far an import of a source went.
+type Progress struct {
+	// Source is the name of the source being imported.
+	Source string
+	// BytesRead is the number of bytes of the source read so far.
+	BytesRead int64
+	// TotalBytes is the size of the source, zero when unknown.
+	TotalBytes int64
+	// Processed is the number of ports handled so far, of which Invalid and Failed were rejected.
+	Processed int
+	Invalid   int
+	Failed    int
+	Elapsed   time.Duration
+	// Rate is the number of ports handled per second since the import started.
+	Rate float64
+	// ETA is the estimated time left until the import completes, zero when unknown.
+	ETA time.Duration
+	// Done is set on the last progress of an import.
+	Done bool
+}
+
+// Percent returns the share of the source read so far as a percentage, or zero when its size is unknown.
+func (p Progress) Percent() float64 {
+	if p.TotalBytes <= 0 {
+		return 0
+	}
+	return 100 * float64(p.BytesRead) / float64(p.TotalBytes)
+}
+
+// String formats the progress for display on a single line.
+func (p Progress) String() string {
+	var b strings.Builder
+	if p.Source != "" {
+		fmt.Fprintf(&b, "%s: ", p.Source)
+	}
+	if p.TotalBytes > 0 {
+		fmt.Fprintf(&b, "%.1f%% of %s, ", p.Percent(), formatBytes(p.TotalBytes))
+	} else {
+		fmt.Fprintf(&b, "%s read, ", formatBytes(p.BytesRead))
+	}
+	fmt.Fprintf(&b, "%d ports (%.0f/s), %d invalid, %d failed", p.Processed, p.Rate, p.Invalid, p.Failed)
+	if p.ETA > 0 {
+		fmt.Fprintf(&b, ", ETA %s", p.ETA.Round(time.Second))
+	}
+	return b.String()
+}
+
+// formatBytes formats a number of bytes with a binary unit.
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%dB", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f%ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+// ProgressHandler is called with the progress of imports at a regular interval, and once more when they complete.
+// Handlers are never called concurrently.
+type ProgressHandler func(progress Progress)
+
+// WithProgressHandler adds a handler of the progress of imports, called at the given interval.
+// Progress is sampled apart from the pipeline, so that it does not slow imports down.
+// Intervals lower than one are ignored.
+func WithProgressHandler(handler ProgressHandler, interval time.Duration) Option {
+	return func(s *PortService) {
+		if interval > 0 {
+			s.onProgress = append(s.onProgress, progressHandler{handle: handler, interval: interval})
+		}
+	}
+}
+
+// SourceProgress is implemented by the readers of sources which transform their content while it is read,
+// e.g. decompressing it, to tell how many bytes of the source they consumed. Imports report their progress
+// against the Size of the source with it, rather than with the bytes read from the reader.
+// It is called concurrently with reads.
+type SourceProgress interface {
+	SourceBytesRead() int64
+}
+
+// progressHandler is a ProgressHandler along with its interval.
+type progressHandler struct {
+	handle   ProgressHandler
+	interval time.Duration
+}
+
+// progressTracker samples the progress of an import and reports it to its handlers.
+// A nil progressTracker does not track anything.
+type progressTracker struct {
+	src      Source
+	input    *countingReader
+	start    time.Time
+	handlers []progressHandler
+
+	processed atomic.Int64
+	invalid   atomic.Int64
+	failed    atomic.Int64
+
+	mu   sync.Mutex
+	stop chan struct{}
+	done sync.WaitGroup
+}
+
+// newProgressTracker starts tracking the progress of the import of the source, or returns nil without handlers.
+func newProgressTracker(handlers []progressHandler, src Source, input *countingReader) *progressTracker {
+	if len(handlers) == 0 {
+		return nil
+	}
+
+	t := &progressTracker{
+		src:      src,
+		input:    input,
+		start:    time.Now(),
+		handlers: handlers,
+		stop:     make(chan struct{}),
+	}
+	for _, handler := range handlers {
+		t.done.Add(1)
+		go t.run(handler)
+	}
+	return t
+}
+
+// update records the counts of the report, as the import goes.
+func (t *progressTracker) update(report *ImportReport) {
+	if t == nil {
+		return
+	}
+	t.processed.Store(int64(report.Processed()))
+	t.invalid.Store(int64(report.Invalid))
+	t.failed.Store(int64(report.Failed))
+}
+
+// finish stops tracking, and reports the final progress of the import.
+func (t *progressTracker) finish() {
+	if t == nil {
+		return
+	}
+	close(t.stop)
+	t.done.Wait()
+
+	progress := t.sample()
+	progress.Done = true
+	for _, handler := range t.handlers {
+		handler.handle(progress)
+	}
+}
+
+func (t *progressTracker) run(handler progressHandler) {
+	defer t.done.Done()
+
+	ticker := time.NewTicker(handler.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-t.stop:
+			return
+		case <-ticker.C:
+			progress := t.sample()
+			// Handlers sharing a tracker are not called concurrently either
+			t.mu.Lock()
+			handler.handle(progress)
+			t.mu.Unlock()
+		}
+	}
+}
+
+// sample returns the current progress of the import.
+func (t *progressTracker) sample() Progress {
+	progress := Progress{
+		Source:     t.src.Name,
+		BytesRead:  t.input.n.Load(),
+		TotalBytes: t.src.Size,
+		Processed:  int(t.processed.Load()),
+		Invalid:    int(t.invalid.Load()),
+		Failed:     int(t.failed.Load()),
+		Elapsed:    time.Since(t.start),
+	}
+	if reader, ok := t.src.Reader.(SourceProgress); ok {
+		progress.BytesRead = reader.SourceBytesRead()
+	}
+
+	if seconds := progress.Elapsed.Seconds(); seconds > 0 {
+		progress.Rate = float64(progress.Processed) / seconds
+	}
+	if progress.TotalBytes > 0 && progress.BytesRead > 0 && progress.BytesRead < progress.TotalBytes {
+		left := float64(progress.TotalBytes-progress.BytesRead) / float64(progress.BytesRead)
+		progress.ETA = time.Duration(float64(progress.Elapsed) * left)
+	}
+	return progress
+}
@@ -0,0 +1,72 @@
+package service_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"ports-service/internal/ports/domain"
+	"ports-service/internal/ports/service"
+)
+
+func TestPortService_LoadSource_Progress(t *testing.T) {
+	ctx := context.Background()
+
+	repo := &slowPortRepository{mockPortRepository: mockPortRepository{ports: make(map[domain.UNLOCODE]domain.Port)}, delay: time.Millisecond}
+	var events []service.Progress
+	portService := service.NewPortService(repo, service.WithWorkers(2), service.WithProgressHandler(func(progress service.Progress) {
+		events = append(events, progress)
+	}, 5*time.Millisecond))
+
+	input := generatePorts(500)
+	src := service.Source{Name: "ports.json", Size: int64(len(input)), Reader: strings.NewReader(input)}
+	_, err := portService.LoadSource(ctx, src)
+	assert.NoError(t, err)
+
+	// Progress is reported while the import goes, and once more when it completes
+	assert.Greater(t, len(events), 2)
+	for i := 1; i < len(events); i++ {
+		assert.GreaterOrEqual(t, events[i].Processed, events[i-1].Processed)
+		assert.GreaterOrEqual(t, events[i].BytesRead, events[i-1].BytesRead)
+		assert.False(t, events[i-1].Done)
+	}
+
+	last := events[len(events)-1]
+	assert.True(t, last.Done)
+	assert.Equal(t, "ports.json", last.Source)
+	assert.Equal(t, 500, last.Processed)
+	assert.Equal(t, int64(len(input)), last.BytesRead)
+	assert.Equal(t, float64(100), last.Percent())
+	assert.Positive(t, last.Rate)
+}
+
+func TestProgress_String(t *testing.T) {
+	tests := []struct {
+		name     string
+		progress service.Progress
+		want     string
+	}{
+		{
+			name: "known size",
+			progress: service.Progress{
+				Source: "ports.json", BytesRead: 3 << 20, TotalBytes: 12 << 20,
+				Processed: 25000, Invalid: 3, Failed: 1, Rate: 5000, ETA: 15 * time.Second,
+			},
+			want: "ports.json: 25.0% of 12.0MiB, 25000 ports (5000/s), 3 invalid, 1 failed, ETA 15s",
+		},
+		{
+			name:     "unknown size",
+			progress: service.Progress{BytesRead: 1536, Processed: 20, Rate: 10},
+			want:     "1.5KiB read, 20 ports (10/s), 0 invalid, 0 failed",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.want, test.progress.String())
+		})
+	}
+}
@@ -0,0 +1,90 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"ports-service/internal/ports/domain"
+)
+
+// ProvenanceRepository is implemented by repositories storing the provenance of each port apart from it.
+// Imports then record where the ports they write come from, see domain.Provenance.
+type ProvenanceRepository interface {
+	// GetProvenance returns the provenance of the port stored with the given UNLOC, or nil when unknown.
+	GetProvenance(ctx context.Context, unloc domain.UNLOCODE) (*domain.Provenance, error)
+	// RecordProvenance updates the provenance of the given ports, written by the origins of the same index,
+	// see domain.Provenance Update. Updates must not be lost to concurrent imports.
+	RecordProvenance(ctx context.Context, ports []domain.Port, origins []domain.Origin) error
+}
+
+// PortLookup is a stored port along with its provenance.
+type PortLookup struct {
+	Port domain.Port `json:"port"`
+	// Provenance is nil when the repository does not record it, or did not for the port yet.
+	Provenance *domain.Provenance `json:"provenance,omitempty"`
+}
+
+// GetPort returns the port stored with the given UNLOC along with its provenance, or nil when it is not stored.
+func (s *PortService) GetPort(ctx context.Context, unloc domain.UNLOCODE) (*PortLookup, error) {
+	port, err := s.repo.GetPortByUNLOC(ctx, unloc)
+	if err != nil || port == nil {
+		return nil, err
+	}
+
+	lookup := &PortLookup{Port: *port}
+	if repo, ok := s.repo.(ProvenanceRepository); ok {
+		if lookup.Provenance, err = repo.GetProvenance(ctx, unloc); err != nil {
+			return nil, fmt.Errorf("failed to get provenance: %w", err)
+		}
+	}
+	return lookup, nil
+}
+
+// sendWritten records the provenance of the ports written successfully when the repository is
+// a ProvenanceRepository, and reports the outcome of the writes. Unchanged ports are recorded as well,
+// so that the ports stored before their provenance get it. The ports whose provenance cannot be
+// recorded are still reported as written, along with the provenance error.
+func (s *PortService) sendWritten(ctx context.Context, origin domain.Origin, written []importResult, results chan<- importResult) {
+	if repo, ok := s.repo.(ProvenanceRepository); ok {
+		var (
+			indexes []int
+			ports   []domain.Port
+			origins []domain.Origin
+		)
+		now := time.Now().UTC()
+		for i, result := range written {
+			if result.err != nil {
+				continue
+			}
+			portOrigin := origin
+			portOrigin.Offset = result.offset
+			portOrigin.ChangedAt = now
+			indexes = append(indexes, i)
+			ports = append(ports, result.port)
+			origins = append(origins, portOrigin)
+		}
+
+		if len(ports) > 0 {
+			if err := repo.RecordProvenance(ctx, ports, origins); err != nil {
+				for _, i := range indexes {
+					written[i].provenanceErr = fmt.Errorf("failed to record provenance: %w", err)
+				}
+			}
+		}
+	}
+
+	for _, result := range written {
+		results <- result
+	}
+}
+
+// newRunID returns a random identifier of an import run.
+func newRunID() string {
+	id := make([]byte, 8)
+	// Reading random bytes does not fail on supported platforms
+	_, _ = rand.Read(id)
+	return hex.EncodeToString(id)
+}
@@ -0,0 +1,82 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"ports-service/internal/infra/repository/inmemory"
+	"ports-service/internal/ports/domain"
+	"ports-service/internal/ports/service"
+)
+
+// failingProvenanceRepository is an in-memory repository failing to record provenance.
+type failingProvenanceRepository struct {
+	*inmemory.PortRepository
+}
+
+func (r failingProvenanceRepository) RecordProvenance(context.Context, []domain.Port, []domain.Origin) error {
+	return errors.New("provenance unavailable")
+}
+
+func TestPortService_LoadSource_RecordsProvenance(t *testing.T) {
+	ctx := context.Background()
+	repo := inmemory.NewPortRepository()
+	portService := service.NewPortService(repo)
+
+	first, err := portService.LoadSource(ctx, service.Source{Name: "ports.json", Fingerprint: "sum-1", Reader: strings.NewReader(samplePorts)})
+	assert.NoError(t, err)
+
+	renamed := `{"unloc": "AEJED", "name": "Jebel Dhanna Port", "city": "Jebel Dhanna", "country": "United Arab Emirates", "province": "Abu Dhabi", "timezone": "Asia/Dubai", "coordinates": [52.6126027, 24.1915137], "unlocs": ["AEJED"], "code": "52050"}`
+	second, err := portService.LoadSource(ctx, service.Source{Name: "fixes.ndjson", Reader: strings.NewReader(renamed)})
+	assert.NoError(t, err)
+	assert.NotEqual(t, first.RunID, second.RunID)
+
+	// The port left as is by the second import keeps the provenance of the first one
+	lookup, err := portService.GetPort(ctx, "AEJEA")
+	assert.NoError(t, err)
+	assert.Equal(t, "Jebel Ali", lookup.Port.Name)
+	assert.Equal(t, "ports.json", lookup.Provenance.Source)
+	assert.Equal(t, "sum-1", lookup.Provenance.Checksum)
+	assert.Equal(t, first.RunID, lookup.Provenance.RunID)
+	assert.Positive(t, lookup.Provenance.Offset)
+	assert.False(t, lookup.Provenance.ChangedAt.IsZero())
+
+	// The renamed port comes from the second import, but only its name does
+	lookup, err = portService.GetPort(ctx, "AEJED")
+	assert.NoError(t, err)
+	assert.Equal(t, "fixes.ndjson", lookup.Provenance.Source)
+	assert.Equal(t, second.RunID, lookup.Provenance.RunID)
+	assert.Equal(t, int64(0), lookup.Provenance.Offset)
+	assert.Equal(t, second.RunID, lookup.Provenance.Fields["name"].RunID)
+	assert.Equal(t, first.RunID, lookup.Provenance.Fields["city"].RunID)
+	assert.NotContains(t, lookup.Provenance.Fields, "alias")
+
+	lookup, err = portService.GetPort(ctx, "AEDXB")
+	assert.NoError(t, err)
+	assert.Nil(t, lookup)
+}
+
+func TestPortService_LoadSource_ReportsProvenanceFailures(t *testing.T) {
+	ctx := context.Background()
+	repo := failingProvenanceRepository{inmemory.NewPortRepository()}
+	portService := service.NewPortService(repo)
+
+	// The ports are written all the same, their provenance failure is reported apart
+	report, err := portService.LoadSource(ctx, service.Source{Name: "ports.json", Fingerprint: "sum-1", Reader: strings.NewReader(samplePorts)})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, report.Inserted)
+	assert.Zero(t, report.Failed)
+	assert.Empty(t, report.Failures)
+	assert.Equal(t, 2, report.ProvenanceFailed)
+	assert.ErrorContains(t, report.ProvenanceErr, "provenance unavailable")
+	assert.Contains(t, report.String(), "provenance not recorded: 2")
+
+	// The import completed, so it left no checkpoint pinned before the ports
+	checkpoint, err := repo.GetCheckpoint(ctx, "ports.json")
+	assert.NoError(t, err)
+	assert.Nil(t, checkpoint)
+}
@@ -0,0 +1,293 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"ports-service/internal/ports/domain"
+)
+
+// maxReportedFailures caps the number of failures kept as a sample in an ImportReport.
+const maxReportedFailures = 100
+
+// ImportReport summarises the outcome of an import.
+type ImportReport struct {
+	// RunID identifies the import run, shared by the sources imported at once. The ports written
+	// are recorded as coming from it, see ProvenanceRepository.
+	RunID string
+	// Inserted is the number of ports that were not stored before.
+	Inserted int
+	// Updated is the number of stored ports that were replaced.
+	Updated int
+	// Unchanged is the number of ports that were already stored as is.
+	Unchanged int
+	// Valid is the number of valid ports found by a dry run, which does not write them.
+	Valid int
+	// Invalid is the number of ports rejected by the port validation, or as duplicates.
+	Invalid int
+	// Failed is the number of ports that could not be written to the repository.
+	Failed int
+	// Dropped is the number of ports decoded but neither written nor rejected because the import was aborted.
+	Dropped int
+	// Deleted is the number of stored ports deleted by a full sync because they are missing from the source.
+	Deleted int
+	// DuplicatePolicy is the policy applied to the UNLOCs found several times in the source.
+	DuplicatePolicy DuplicatePolicy
+	// Duplicates is the number of ports repeating an UNLOC found earlier in the source.
+	Duplicates int
+	// Unmerged is the number of duplicates a dry run could not merge into their earlier occurrence, as the
+	// ports it keeps to do so reached their share of the memory budget. They are validated on their own.
+	Unmerged int
+	// DuplicateKeys is a sample of the UNLOCs found several times, capped to the first maxReportedFailures.
+	DuplicateKeys []DuplicateKey
+	// Skipped is the number of ports skipped because a previous import of the same source committed them.
+	Skipped int
+	// ProvenanceFailed is the number of ports written whose provenance could not be recorded, see
+	// ProvenanceRepository. They are counted as written nonetheless, and get their provenance on their next import.
+	ProvenanceFailed int
+	// ProvenanceErr is the first error met recording provenance.
+	ProvenanceErr error
+	// BytesRead is the number of bytes read from the input.
+	BytesRead int64
+	// Elapsed is the duration of the import.
+	Elapsed time.Duration
+	// Failures is a sample of the invalid and failed ports, capped to the first maxReportedFailures.
+	Failures []ImportFailure
+	// Sources breaks the report down per source, for imports of several sources.
+	Sources []SourceReport
+}
+
+// ImportStage is a stage of an import at which ports can be rejected.
+type ImportStage string
+
+const (
+	// StageValidate rejects the ports failing validation.
+	StageValidate ImportStage = "validate"
+	// StageDuplicate rejects the ports repeating an UNLOC with the RejectDuplicates policy.
+	StageDuplicate ImportStage = "duplicate"
+	// StageWrite rejects the ports that could not be written to the repository.
+	StageWrite ImportStage = "write"
+)
+
+// ImportFailure describes why a port could not be imported.
+type ImportFailure struct {
+	UNLOC domain.UNLOCODE
+	// Stage is the stage of the import which rejected the port.
+	Stage ImportStage
+	// Source is the name of the source holding the port, if any.
+	Source string
+	// Offset is the byte offset of the port in the source.
+	Offset int64
+	Reason string
+	// Fields holds the fields failing validation, it is empty when the port could not be written.
+	Fields []domain.FieldError
+	// Record is the port as found in the source, or as decoded when the source is not JSON.
+	Record json.RawMessage
+}
+
+// Processed returns the number of ports handled by the import.
+func (r *ImportReport) Processed() int {
+	return r.Inserted + r.Updated + r.Unchanged + r.Valid + r.Invalid + r.Failed
+}
+
+// String formats the report for display.
+func (r *ImportReport) String() string {
+	var b strings.Builder
+	if r.RunID != "" {
+		fmt.Fprintf(&b, "Import run: %s\n", r.RunID)
+	}
+	fmt.Fprintf(&b, "Ports processed: %d in %s (%d bytes read)\n", r.Processed(), r.Elapsed.Round(time.Millisecond), r.BytesRead)
+	if r.Valid > 0 {
+		fmt.Fprintf(&b, "  valid:     %d (not written by the dry run)\n", r.Valid)
+	} else {
+		fmt.Fprintf(&b, "  inserted:  %d\n", r.Inserted)
+		fmt.Fprintf(&b, "  updated:   %d\n", r.Updated)
+		fmt.Fprintf(&b, "  unchanged: %d\n", r.Unchanged)
+	}
+	fmt.Fprintf(&b, "  invalid:   %d\n", r.Invalid)
+	fmt.Fprintf(&b, "  failed:    %d\n", r.Failed)
+	if r.Dropped > 0 {
+		fmt.Fprintf(&b, "  dropped:   %d (import aborted)\n", r.Dropped)
+	}
+	if r.Deleted > 0 {
+		fmt.Fprintf(&b, "  deleted:   %d (missing from the source)\n", r.Deleted)
+	}
+	if r.Skipped > 0 {
+		fmt.Fprintf(&b, "  skipped:   %d (already imported by a previous run)\n", r.Skipped)
+	}
+	if r.ProvenanceFailed > 0 {
+		fmt.Fprintf(&b, "  provenance not recorded: %d (%v)\n", r.ProvenanceFailed, r.ProvenanceErr)
+	}
+	if r.Duplicates > 0 {
+		fmt.Fprintf(&b, "  duplicates: %d (%s)\n", r.Duplicates, r.DuplicatePolicy)
+	}
+	if r.Unmerged > 0 {
+		fmt.Fprintf(&b, "  unmerged:  %d (duplicates validated on their own, past the memory budget)\n", r.Unmerged)
+	}
+	if len(r.DuplicateKeys) > 0 {
+		fmt.Fprintf(&b, "Duplicate keys (showing %d):\n", len(r.DuplicateKeys))
+		for _, key := range r.DuplicateKeys {
+			fmt.Fprintf(&b, "  %s at offsets %v\n", key.UNLOC, key.Offsets)
+		}
+	}
+	if len(r.Sources) > 1 {
+		b.WriteString("Sources:\n")
+		for _, src := range r.Sources {
+			switch {
+			case src.Missing:
+				fmt.Fprintf(&b, "  %s: skipped (%v)\n", src.Name, src.Err)
+			case src.Err != nil:
+				fmt.Fprintf(&b, "  %s: %d processed, failed (%v)\n", src.Name, src.Report.Processed(), src.Err)
+			default:
+				fmt.Fprintf(&b, "  %s: %d processed, %d invalid, %d failed\n", src.Name, src.Report.Processed(), src.Report.Invalid, src.Report.Failed)
+			}
+		}
+	}
+	if len(r.Failures) > 0 {
+		fmt.Fprintf(&b, "Failures (showing %d of %d):\n", len(r.Failures), r.Invalid+r.Failed)
+		for _, failure := range r.Failures {
+			if len(r.Sources) > 1 {
+				fmt.Fprintf(&b, "  %s (%s): %s\n", failure.UNLOC, failure.Source, failure.Reason)
+			} else {
+				fmt.Fprintf(&b, "  %s: %s\n", failure.UNLOC, failure.Reason)
+			}
+		}
+	}
+	return b.String()
+}
+
+// add adds the outcome of the import of another source to the report.
+func (r *ImportReport) add(other ImportReport) {
+	r.Inserted += other.Inserted
+	r.Updated += other.Updated
+	r.Unchanged += other.Unchanged
+	r.Valid += other.Valid
+	r.Invalid += other.Invalid
+	r.Failed += other.Failed
+	r.Dropped += other.Dropped
+	r.DuplicatePolicy = other.DuplicatePolicy
+	r.Duplicates += other.Duplicates
+	r.Unmerged += other.Unmerged
+	r.Skipped += other.Skipped
+	r.ProvenanceFailed += other.ProvenanceFailed
+	if r.ProvenanceErr == nil {
+		r.ProvenanceErr = other.ProvenanceErr
+	}
+	r.BytesRead += other.BytesRead
+	r.DuplicateKeys = appendCapped(r.DuplicateKeys, other.DuplicateKeys)
+	r.Failures = appendCapped(r.Failures, other.Failures)
+}
+
+// appendCapped appends the elements to the slice, up to maxReportedFailures elements.
+func appendCapped[T any](s, elems []T) []T {
+	if n := maxReportedFailures - len(s); len(elems) > n {
+		elems = elems[:n]
+	}
+	return append(s, elems...)
+}
+
+// importResult is the outcome of a single record, reported by the pipeline stages.
+type importResult struct {
+	record
+	status domain.UpsertStatus
+	// stage is the stage which rejected the record, when err is set.
+	stage ImportStage
+	err   error
+	// dropped is set when the record was dropped because the import was aborted.
+	dropped bool
+	// provenanceErr is set when the port was written but its provenance could not be recorded.
+	provenanceErr error
+}
+
+// record updates the report with the outcome of a single record of the given source.
+// It returns the failure of rejected records, and nil otherwise.
+func (r *ImportReport) record(result importResult, source string) *ImportFailure {
+	if result.unmerged {
+		r.Unmerged++
+	}
+	if result.provenanceErr != nil {
+		r.ProvenanceFailed++
+		if r.ProvenanceErr == nil {
+			r.ProvenanceErr = result.provenanceErr
+		}
+	}
+
+	switch {
+	case result.dropped:
+		r.Dropped++
+	case result.err != nil:
+		if result.stage == StageValidate || result.stage == StageDuplicate {
+			r.Invalid++
+		} else {
+			r.Failed++
+		}
+
+		failure := result.failure(source)
+		if len(r.Failures) < maxReportedFailures {
+			r.Failures = append(r.Failures, failure)
+		}
+		return &failure
+	case result.status == domain.Inserted:
+		r.Inserted++
+	case result.status == domain.Updated:
+		r.Updated++
+	case result.status == domain.Unchanged:
+		r.Unchanged++
+	default:
+		// Valid ports of a dry run are not written, so they have no status
+		r.Valid++
+	}
+	return nil
+}
+
+// failure describes the failure of a rejected record of the given source.
+func (r importResult) failure(source string) ImportFailure {
+	failure := ImportFailure{
+		UNLOC:  r.port.UNLOC,
+		Stage:  r.stage,
+		Source: source,
+		Offset: r.offset,
+		Reason: r.err.Error(),
+		Record: r.raw,
+	}
+
+	var validationErr *domain.ValidationError
+	if errors.As(r.err, &validationErr) {
+		failure.Fields = validationErr.Fields
+	}
+	if failure.Record == nil {
+		// Ports always marshal, and they hold their UNLOC unlike some raw records
+		failure.Record, _ = json.Marshal(r.port)
+	}
+	return failure
+}
+
+// failureHandlers calls the failure handlers of an import, until they fail.
+type failureHandlers struct {
+	handlers []FailureHandler
+	errs     []error
+}
+
+func newFailureHandlers(handlers []FailureHandler) *failureHandlers {
+	return &failureHandlers{
+		handlers: handlers,
+		errs:     make([]error, len(handlers)),
+	}
+}
+
+// handle calls the handlers which did not fail yet with the failure.
+func (h *failureHandlers) handle(failure ImportFailure) {
+	for i, handler := range h.handlers {
+		if h.errs[i] == nil {
+			h.errs[i] = handler(failure)
+		}
+	}
+}
+
+// err returns the errors of the failed handlers.
+func (h *failureHandlers) err() error {
+	return errors.Join(h.errs...)
+}
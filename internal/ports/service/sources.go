@@ -0,0 +1,118 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// MissingSourcePolicy tells what imports of several sources do with the sources which cannot be opened,
+// e.g. because they are missing or unreadable.
+type MissingSourcePolicy string
+
+const (
+	// FailMissingSources fails the import on the first source which cannot be opened. It is the default.
+	FailMissingSources MissingSourcePolicy = "fail"
+	// SkipMissingSources skips the sources which cannot be opened, reporting them.
+	SkipMissingSources MissingSourcePolicy = "skip"
+)
+
+// errIncompleteSync is returned by full syncs of several sources when some of them were skipped,
+// as the ports they hold would be deleted.
+var errIncompleteSync = errors.New("sources were skipped, refusing to delete the ports missing from the others")
+
+func (p MissingSourcePolicy) validate() error {
+	switch p {
+	case FailMissingSources, SkipMissingSources:
+		return nil
+	}
+	return fmt.Errorf("unknown missing source policy %q", p)
+}
+
+// WithMissingSourcePolicy sets what imports of several sources do with the sources which cannot be opened.
+// Imports with an unknown policy fail.
+func WithMissingSourcePolicy(policy MissingSourcePolicy) Option {
+	return func(s *PortService) {
+		s.missingSources = policy
+	}
+}
+
+// SourceReport is the outcome of the import of one of several sources.
+type SourceReport struct {
+	Name string
+	// Missing is set when the source could not be opened and was skipped, Err telling why.
+	Missing bool
+	Err     error
+	// Report is the report of the import of the source, which does not break down any further.
+	Report ImportReport
+}
+
+// LoadSources loads ports from several sources in the given order, opening each of them by name once
+// the previous one was imported, and closing its reader when it is an io.Closer. Each source is imported
+// as LoadSource does, with its own ErrorPolicy, duplicates and checkpoint, so that a port found in several
+// sources ends up as found in the last one. The returned report totals the imports of all the sources,
+// and breaks them down in its Sources.
+//
+// The import stops on the first source failing, or which cannot be opened unless the MissingSourcePolicy
+// skips it. With WithFullSync, the stored ports missing from all the sources are deleted once they were
+// all imported, which is refused when sources were skipped.
+func (s *PortService) LoadSources(ctx context.Context, names []string, open func(name string) (Source, error)) (ImportReport, error) {
+	start := time.Now()
+	if err := s.duplicates.validate(); err != nil {
+		return ImportReport{}, err
+	}
+	if err := s.missingSources.validate(); err != nil {
+		return ImportReport{}, err
+	}
+	if err := s.merge.Validate(); err != nil {
+		return ImportReport{}, fmt.Errorf("invalid merge policy: %w", err)
+	}
+
+	report := ImportReport{RunID: newRunID()}
+	var seen keySet
+	skipped := false
+	for _, name := range names {
+		if err := ctx.Err(); err != nil {
+			report.Elapsed = time.Since(start)
+			return report, fmt.Errorf("import canceled: %w", err)
+		}
+
+		src, err := open(name)
+		if err != nil {
+			report.Sources = append(report.Sources, SourceReport{Name: name, Missing: true, Err: err})
+			if s.missingSources == SkipMissingSources {
+				skipped = true
+				continue
+			}
+			report.Elapsed = time.Since(start)
+			return report, fmt.Errorf("%s: %w", name, err)
+		}
+
+		sourceReport, sourceSeen, err := s.load(ctx, src, report.RunID)
+		if closer, ok := src.Reader.(io.Closer); ok {
+			closer.Close()
+		}
+		report.add(sourceReport)
+		report.Sources = append(report.Sources, SourceReport{Name: name, Err: err, Report: sourceReport})
+		if sourceSeen != nil {
+			seen.union(sourceSeen)
+		}
+		if err != nil {
+			report.Elapsed = time.Since(start)
+			return report, fmt.Errorf("%s: %w", name, err)
+		}
+	}
+
+	var err error
+	if s.fullSync && !s.dryRun {
+		if skipped {
+			err = errIncompleteSync
+		} else {
+			report.Deleted, err = s.syncPorts(ctx, &seen)
+		}
+	}
+	report.Elapsed = time.Since(start)
+	return report, err
+}
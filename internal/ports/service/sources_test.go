@@ -0,0 +1,123 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"ports-service/internal/ports/domain"
+	"ports-service/internal/ports/service"
+)
+
+// multipleSources holds several sources by name, the ones missing failing to open.
+type multipleSources map[string]string
+
+func (m multipleSources) open(name string) (service.Source, error) {
+	input, exists := m[name]
+	if !exists {
+		return service.Source{}, fs.ErrNotExist
+	}
+	return service.Source{Name: name, Reader: strings.NewReader(input)}, nil
+}
+
+func TestPortService_LoadSources(t *testing.T) {
+	ctx := context.Background()
+
+	sources := multipleSources{
+		"ports-1.json": samplePorts,
+		"ports-2.ndjson": `{"unloc": "AEJED", "name": "Jebel Dhanna Port", "city": "Jebel Dhanna", "country": "United Arab Emirates"}
+{"unloc": "AEAJM", "name": "Ajman", "city": "Ajman", "country": "United Arab Emirates"}
+{"unloc": "AEJEB", "name": "Invalid Port"}`,
+	}
+
+	repo := &mockPortRepository{ports: make(map[domain.UNLOCODE]domain.Port)}
+	portService := service.NewPortService(repo)
+
+	report, err := portService.LoadSources(ctx, []string{"ports-1.json", "ports-2.ndjson"}, sources.open)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, report.Inserted)
+	assert.Equal(t, 1, report.Updated)
+	assert.Equal(t, 1, report.Invalid)
+	assert.Equal(t, "ports-2.ndjson", report.Failures[0].Source)
+
+	assert.Len(t, report.Sources, 2)
+	assert.Equal(t, "ports-1.json", report.Sources[0].Name)
+	assert.Equal(t, 2, report.Sources[0].Report.Inserted)
+	assert.Equal(t, "ports-2.ndjson", report.Sources[1].Name)
+	assert.Equal(t, 1, report.Sources[1].Report.Inserted)
+	assert.Equal(t, 1, report.Sources[1].Report.Updated)
+
+	// The port found in both sources ends up as found in the last one
+	assert.Equal(t, "Jebel Dhanna Port", repo.ports["AEJED"].Name)
+}
+
+func TestPortService_LoadSources_MissingSourcePolicy(t *testing.T) {
+	ctx := context.Background()
+
+	sources := multipleSources{"ports-1.json": samplePorts}
+	names := []string{"missing.json", "ports-1.json"}
+
+	tests := []struct {
+		name     string
+		policy   service.MissingSourcePolicy
+		wantErr  bool
+		inserted int
+	}{
+		{name: "fail", policy: service.FailMissingSources, wantErr: true},
+		{name: "skip", policy: service.SkipMissingSources, inserted: 2},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			repo := &mockPortRepository{ports: make(map[domain.UNLOCODE]domain.Port)}
+			portService := service.NewPortService(repo, service.WithMissingSourcePolicy(test.policy))
+
+			report, err := portService.LoadSources(ctx, names, sources.open)
+			if test.wantErr {
+				assert.ErrorIs(t, err, fs.ErrNotExist)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, test.inserted, report.Inserted)
+			assert.True(t, report.Sources[0].Missing)
+			assert.True(t, errors.Is(report.Sources[0].Err, fs.ErrNotExist))
+		})
+	}
+
+	_, err := service.NewPortService(&mockPortRepository{}, service.WithMissingSourcePolicy("random")).LoadSources(ctx, names, sources.open)
+	assert.Error(t, err)
+}
+
+func TestPortService_LoadSources_FullSync(t *testing.T) {
+	ctx := context.Background()
+
+	sources := multipleSources{
+		"ports-1.json":   samplePorts,
+		"ports-2.ndjson": `{"unloc": "AEAJM", "name": "Ajman", "city": "Ajman", "country": "United Arab Emirates"}`,
+	}
+	stale := domain.Port{UNLOC: "AEDXB", Name: "Dubai", City: "Dubai", Country: "United Arab Emirates"}
+
+	t.Run("deletes the ports missing from all the sources", func(t *testing.T) {
+		repo := &mockPortRepository{ports: map[domain.UNLOCODE]domain.Port{stale.UNLOC: stale}}
+		portService := service.NewPortService(repo, service.WithFullSync())
+
+		report, err := portService.LoadSources(ctx, []string{"ports-1.json", "ports-2.ndjson"}, sources.open)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, report.Deleted)
+		assert.Len(t, repo.ports, 3)
+		assert.NotContains(t, repo.ports, stale.UNLOC)
+	})
+
+	t.Run("does not delete ports when sources were skipped", func(t *testing.T) {
+		repo := &mockPortRepository{ports: map[domain.UNLOCODE]domain.Port{stale.UNLOC: stale}}
+		portService := service.NewPortService(repo, service.WithFullSync(), service.WithMissingSourcePolicy(service.SkipMissingSources))
+
+		_, err := portService.LoadSources(ctx, []string{"ports-1.json", "missing.json"}, sources.open)
+		assert.Error(t, err)
+		assert.Contains(t, repo.ports, stale.UNLOC)
+	})
+}
@@ -0,0 +1,56 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"ports-service/internal/ports/domain"
+)
+
+// errEmptySync is returned by full syncs of sources holding no ports, which would delete all the stored ports.
+var errEmptySync = errors.New("the source holds no ports, refusing to delete all the stored ports")
+
+// syncPorts deletes the stored ports whose UNLOC is not in seen, once a full sync import succeeded.
+func (s *PortService) syncPorts(ctx context.Context, seen *keySet) (int, error) {
+	deleted, err := s.deleteStalePorts(ctx, seen)
+	if err != nil {
+		return deleted, fmt.Errorf("failed to delete stale ports: %w", err)
+	}
+	return deleted, nil
+}
+
+// deleteStalePorts deletes the stored ports whose UNLOC is not in seen, and returns how many were deleted.
+// The ports are deleted in batches of up to batchSize UNLOCs.
+func (s *PortService) deleteStalePorts(ctx context.Context, seen *keySet) (int, error) {
+	if seen.len() == 0 {
+		return 0, errEmptySync
+	}
+
+	deleted := 0
+	err := s.repo.ScanUNLOCs(ctx, func(unlocs []domain.UNLOCODE) error {
+		stale := make([]domain.UNLOCODE, 0, s.batchSize)
+		for _, unloc := range unlocs {
+			if seen.contains(unloc) {
+				continue
+			}
+			stale = append(stale, unloc)
+			if len(stale) == s.batchSize {
+				if err := s.repo.DeletePorts(ctx, stale); err != nil {
+					return err
+				}
+				deleted += len(stale)
+				stale = stale[:0]
+			}
+		}
+		if len(stale) == 0 {
+			return nil
+		}
+		if err := s.repo.DeletePorts(ctx, stale); err != nil {
+			return err
+		}
+		deleted += len(stale)
+		return nil
+	})
+	return deleted, err
+}